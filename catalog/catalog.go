@@ -0,0 +1,181 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package catalog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"unicode"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultTypeName = "Events"
+
+// Catalog declares the alerts and statuses of a connector
+type Catalog struct {
+	// Package is the name of the package of the generated code
+	Package string `yaml:"package"`
+	// Type is the name of the generated struct holding the events, Events by default
+	Type     string   `yaml:"type"`
+	Alerts   []*Event `yaml:"alerts"`
+	Statuses []*Event `yaml:"statuses"`
+}
+
+// Event declares an alert or a status
+type Event struct {
+	Name        string `yaml:"name"`
+	Message     string `yaml:"message"`
+	Description string `yaml:"description"`
+	// Severity is the name of a connector.v1.Severity value with or without the SEVERITY_ prefix. Only used by alerts.
+	Severity string `yaml:"severity"`
+	// State is the name of a connector.v1.State value with or without the STATE_ prefix
+	State    string         `yaml:"state"`
+	Metadata []*MetadataKey `yaml:"metadata"`
+}
+
+// MetadataKey documents a metadata property published with an event
+type MetadataKey struct {
+	Key         string `yaml:"key"`
+	Description string `yaml:"description"`
+}
+
+// Load reads and validates the YAML or JSON catalog in the file
+func Load(path string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid catalog %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse decodes and validates a YAML or JSON catalog
+func Parse(data []byte) (*Catalog, error) {
+	c := &Catalog{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return nil, err
+	}
+	if c.Type == "" {
+		c.Type = defaultTypeName
+	}
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Catalog) validate() error {
+	if !isIdentifier(c.Package) {
+		return fmt.Errorf("package %q is not a valid Go identifier", c.Package)
+	}
+	if !isIdentifier(c.Type) {
+		return fmt.Errorf("type %q is not a valid Go identifier", c.Type)
+	}
+
+	names := map[string]bool{}
+	fields := map[string]string{}
+	check := func(kind string, e *Event) error {
+		if e.Name == "" {
+			return fmt.Errorf("%s without a name", kind)
+		}
+		if names[kind+"/"+e.Name] {
+			return fmt.Errorf("duplicate %s %s", kind, e.Name)
+		}
+		names[kind+"/"+e.Name] = true
+		field := e.FieldName()
+		if field == "" {
+			return fmt.Errorf("%s %s has no letters to derive a Go name from", kind, e.Name)
+		}
+		if other, ok := fields[field]; ok {
+			return fmt.Errorf("%s %s and %s map to the same Go name %s", kind, e.Name, other, field)
+		}
+		fields[field] = e.Name
+		if e.Message == "" {
+			return fmt.Errorf("%s %s without a message", kind, e.Name)
+		}
+		if _, err := parseState(e.State); err != nil {
+			return fmt.Errorf("%s %s: %w", kind, e.Name, err)
+		}
+		return nil
+	}
+
+	for _, e := range c.Alerts {
+		if err := check("alert", e); err != nil {
+			return err
+		}
+		if _, err := parseSeverity(e.Severity); err != nil {
+			return fmt.Errorf("alert %s: %w", e.Name, err)
+		}
+	}
+	for _, e := range c.Statuses {
+		if err := check("status", e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FieldName returns the exported Go name of the event, e.g. UnableToFetchData for unableToFetchData or unable-to-fetch-data
+func (e *Event) FieldName() string {
+	var b strings.Builder
+	upper := true
+	for _, r := range e.Name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && !unicode.IsLetter(r) {
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func parseSeverity(name string) (connectorpb.Severity, error) {
+	value, ok := connectorpb.Severity_value[enumName("SEVERITY_", name)]
+	if !ok || value == int32(connectorpb.Severity_SEVERITY_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown severity %q", name)
+	}
+	return connectorpb.Severity(value), nil
+}
+
+func parseState(name string) (connectorpb.State, error) {
+	value, ok := connectorpb.State_value[enumName("STATE_", name)]
+	if !ok || value == int32(connectorpb.State_STATE_UNSPECIFIED) {
+		return 0, fmt.Errorf("unknown state %q", name)
+	}
+	return connectorpb.State(value), nil
+}
+
+func enumName(prefix string, name string) string {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, prefix) {
+		name = prefix + name
+	}
+	return name
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
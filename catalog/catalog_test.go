@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package catalog
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCatalog = `
+package: connector
+alerts:
+  - name: unableToFetchData
+    message: "unable to fetch data from {{.Host}}"
+    severity: CRITICAL
+    state: STATE_FAILED
+    description: Raised when the source | sink cannot be reached
+    metadata:
+      - key: Host
+        description: Host of the source system
+statuses:
+  - name: db-health
+    message: database health
+    state: healthy
+`
+
+func TestCatalog(t *testing.T) {
+	t.Run("yaml and json catalogs are parsed", func(t *testing.T) {
+		c, err := Parse([]byte(testCatalog))
+		require.NoError(t, err)
+		assert.Equal(t, "Events", c.Type)
+		require.Len(t, c.Alerts, 1)
+		assert.Equal(t, "Host", c.Alerts[0].Metadata[0].Key)
+		require.Len(t, c.Statuses, 1)
+		assert.Equal(t, "DbHealth", c.Statuses[0].FieldName())
+
+		path := filepath.Join(t.TempDir(), "events.json")
+		json := `{"package": "connector", "type": "ConnectorEvents", "statuses": [{"name": "lagging", "message": "lagging", "state": "UNHEALTHY"}]}`
+		require.NoError(t, ioutil.WriteFile(path, []byte(json), 0644))
+		c, err = Load(path)
+		require.NoError(t, err)
+		assert.Equal(t, "ConnectorEvents", c.Type)
+		assert.Len(t, c.Statuses, 1)
+	})
+
+	t.Run("invalid catalogs are rejected", func(t *testing.T) {
+		for _, invalid := range []string{
+			`alerts: []`,
+			`{package: connector, unknown: true}`,
+			`{package: connector, alerts: [{name: a, message: a, severity: LOUD, state: FAILED}]}`,
+			`{package: connector, statuses: [{name: a, message: a, state: BROKEN}]}`,
+			`{package: connector, statuses: [{name: a, state: FAILED}]}`,
+			`{package: connector, statuses: [{name: a-b, message: a, state: FAILED}, {name: aB, message: a, state: FAILED}]}`,
+		} {
+			_, err := Parse([]byte(invalid))
+			assert.Error(t, err, invalid)
+		}
+	})
+
+	t.Run("generated go code registers the events", func(t *testing.T) {
+		c, err := Parse([]byte(testCatalog))
+		require.NoError(t, err)
+
+		code, err := c.GenerateGo("events.yaml")
+		require.NoError(t, err)
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "events_gen.go", code, 0)
+		require.NoError(t, err)
+		conf := types.Config{Importer: exportDataImporter(t, fset, "github.com/nutanix/kps-connector-go-sdk/events")}
+		_, err = conf.Check("connector", fset, []*ast.File{file}, nil)
+		require.NoError(t, err, "generated code does not type-check against the events package")
+		assert.Contains(t, string(code), "// Code generated by eventgen from events.yaml. DO NOT EDIT.")
+		assert.Contains(t, string(code), `UnableToFetchData: events.NewAlert("unableToFetchData", "unable to fetch data from {{.Host}}", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED, opts...)`)
+		assert.Contains(t, string(code), `events.NewStatus("db-health", "database health", connectorpb.State_STATE_HEALTHY, opts...)`)
+		assert.Contains(t, string(code), "registry.RegisterStatus(e.DbHealth)")
+		assert.Contains(t, string(code), "// UnableToFetchData is the unableToFetchData alert. Raised when the source | sink cannot be reached")
+	})
+
+	t.Run("markdown page documents the events", func(t *testing.T) {
+		c, err := Parse([]byte(testCatalog))
+		require.NoError(t, err)
+
+		page, err := c.GenerateMarkdown("events.yaml")
+		require.NoError(t, err)
+		assert.Contains(t, string(page), "| unableToFetchData | CRITICAL | FAILED | unable to fetch data from {{.Host}} | Raised when the source \\| sink cannot be reached |")
+		assert.Contains(t, string(page), "| db-health | HEALTHY | database health |  |")
+		assert.Contains(t, string(page), "| Host | Host of the source system |")
+	})
+}
+
+// exportDataImporter returns an importer reading the compiler export data of the package and its dependencies
+func exportDataImporter(t *testing.T, fset *token.FileSet, pkg string) types.Importer {
+	out, err := exec.Command("go", "list", "-export", "-deps", "-f", "{{.ImportPath}}={{.Export}}", pkg).Output()
+	require.NoError(t, err)
+	exports := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if parts := strings.SplitN(line, "=", 2); len(parts) == 2 && parts[1] != "" {
+			exports[parts[0]] = parts[1]
+		}
+	}
+	return importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		export, ok := exports[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %s", path)
+		}
+		return os.Open(export)
+	})
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2021 Nutanix, Inc.
+/*
+Package catalog reads a declarative catalog of the alerts and statuses of a connector and generates the Go code that
+creates and registers them with an events.Registry, along with a Markdown reference page for operators. This keeps
+the names, messages, severities and states of the events in one place instead of scattered across calls to
+`NewAlert` and `NewStatus`.
+
+A catalog is written in YAML or JSON e.g.
+	package: connector
+	alerts:
+	  - name: unableToFetchData
+	    message: unable to fetch data from {{.Host}}
+	    severity: CRITICAL
+	    state: FAILED
+	    description: Raised when the source system cannot be reached
+	    metadata:
+	      - key: Host
+	        description: Host of the source system
+	statuses:
+	  - name: unableToContactDB
+	    message: unable to contact the database
+	    state: UNHEALTHY
+
+Severities and states are the names of the values of the connector.v1 enums, with or without their prefix. The
+generated code declares a struct, named Events unless the catalog sets `type`, holding a field per event and a
+constructor registering them e.g.
+	connectorEvents, err := NewEvents(registry)
+	connectorEvents.UnableToFetchData.Publish(events.AlertWithMessageData(...))
+
+The code is usually generated with the eventgen command e.g.
+	//go:generate go run github.com/nutanix/kps-connector-go-sdk/cmd/eventgen -catalog events.yaml -go events_gen.go -markdown EVENTS.md
+*/
+package catalog
@@ -0,0 +1,166 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package catalog
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+var goTemplate = template.Must(template.New("go").Funcs(template.FuncMap{
+	"quote":    strconv.Quote,
+	"severity": func(name string) string { return severityName(name, "") },
+	"state":    func(name string) string { return stateName(name, "") },
+	"comment":  comment,
+}).Parse(`// Code generated by eventgen from {{.Source}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+)
+
+// {{.Type}} holds the alerts and statuses declared in the event catalog
+type {{.Type}} struct {
+{{- range .Alerts}}
+	// {{.FieldName}} is the {{.Name}} alert{{with comment .Description}}. {{.}}{{end}}
+	{{.FieldName}} events.Alert
+{{- end}}
+{{- range .Statuses}}
+	// {{.FieldName}} is the {{.Name}} status{{with comment .Description}}. {{.}}{{end}}
+	{{.FieldName}} events.Status
+{{- end}}
+}
+
+// New{{.Type}} creates the alerts and statuses declared in the event catalog and registers them with the registry
+func New{{.Type}}(registry *events.Registry, opts ...events.EventOpts) (*{{.Type}}, error) {
+	e := &{{.Type}}{
+{{- range .Alerts}}
+		{{.FieldName}}: events.NewAlert({{quote .Name}}, {{quote .Message}}, connectorpb.Severity_{{severity .Severity}}, connectorpb.State_{{state .State}}, opts...),
+{{- end}}
+{{- range .Statuses}}
+		{{.FieldName}}: events.NewStatus({{quote .Name}}, {{quote .Message}}, connectorpb.State_{{state .State}}, opts...),
+{{- end}}
+	}
+{{- range .Alerts}}
+	if err := registry.RegisterAlert(e.{{.FieldName}}); err != nil {
+		return nil, err
+	}
+{{- end}}
+{{- range .Statuses}}
+	if err := registry.RegisterStatus(e.{{.FieldName}}); err != nil {
+		return nil, err
+	}
+{{- end}}
+	return e, nil
+}
+`))
+
+var markdownTemplate = template.Must(template.New("markdown").Funcs(template.FuncMap{
+	"severity": func(name string) string { return severityName(name, "SEVERITY_") },
+	"state":    func(name string) string { return stateName(name, "STATE_") },
+	"cell":     cell,
+}).Parse(`# Events
+
+This page is generated by eventgen from {{.Source}}.
+{{- if .Alerts}}
+
+## Alerts
+
+| Name | Severity | State | Message | Description |
+| --- | --- | --- | --- | --- |
+{{- range .Alerts}}
+| {{cell .Name}} | {{severity .Severity}} | {{state .State}} | {{cell .Message}} | {{cell .Description}} |
+{{- end}}
+{{- end}}
+{{- if .Statuses}}
+
+## Statuses
+
+| Name | State | Message | Description |
+| --- | --- | --- | --- |
+{{- range .Statuses}}
+| {{cell .Name}} | {{state .State}} | {{cell .Message}} | {{cell .Description}} |
+{{- end}}
+{{- end}}
+{{- range $kind, $events := .Documented}}
+{{- range $events}}
+
+### {{$kind}} {{.Name}}
+
+| Metadata key | Description |
+| --- | --- |
+{{- range .Metadata}}
+| {{cell .Key}} | {{cell .Description}} |
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+type templateData struct {
+	*Catalog
+	Source string
+}
+
+// Documented returns the events with documented metadata keys by kind
+func (d templateData) Documented() map[string][]*Event {
+	documented := map[string][]*Event{}
+	for kind, list := range map[string][]*Event{"Alert": d.Alerts, "Status": d.Statuses} {
+		for _, e := range list {
+			if len(e.Metadata) > 0 {
+				documented[kind] = append(documented[kind], e)
+			}
+		}
+	}
+	return documented
+}
+
+// GenerateGo returns the formatted Go code creating and registering the events of the catalog. The source
+// is the name of the catalog file mentioned in the generated code.
+func (c *Catalog) GenerateGo(source string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, templateData{Catalog: c, Source: source}); err != nil {
+		return nil, err
+	}
+	code, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("unable to format generated code: %w", err)
+	}
+	return code, nil
+}
+
+// GenerateMarkdown returns a Markdown reference page of the events of the catalog
+func (c *Catalog) GenerateMarkdown(source string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownTemplate.Execute(&buf, templateData{Catalog: c, Source: source}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// severityName returns the name of the severity value of a validated catalog without the prefix
+func severityName(name string, prefix string) string {
+	severity, _ := parseSeverity(name)
+	return strings.TrimPrefix(severity.String(), prefix)
+}
+
+// stateName returns the name of the state value of a validated catalog without the prefix
+func stateName(name string, prefix string) string {
+	state, _ := parseState(name)
+	return strings.TrimPrefix(state.String(), prefix)
+}
+
+// comment returns the description on a single line for use in a doc comment
+func comment(description string) string {
+	return strings.Join(strings.Fields(description), " ")
+}
+
+// cell escapes the text for use in a Markdown table cell
+func cell(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	return strings.ReplaceAll(text, "|", "\\|")
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Nutanix, Inc.
+
+// Command eventgen generates the Go code registering the alerts and statuses declared in an event catalog,
+// and a Markdown reference page of the events for operators e.g.
+//
+//	//go:generate go run github.com/nutanix/kps-connector-go-sdk/cmd/eventgen -catalog events.yaml -go events_gen.go -markdown EVENTS.md
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nutanix/kps-connector-go-sdk/catalog"
+)
+
+func main() {
+	catalogPath := flag.String("catalog", "", "path of the YAML or JSON event catalog")
+	goPath := flag.String("go", "", "path of the generated Go file")
+	markdownPath := flag.String("markdown", "", "path of the generated Markdown reference page")
+	flag.Parse()
+
+	if err := run(*catalogPath, *goPath, *markdownPath); err != nil {
+		fmt.Fprintf(os.Stderr, "eventgen: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(catalogPath string, goPath string, markdownPath string) error {
+	if catalogPath == "" {
+		return fmt.Errorf("-catalog is required")
+	}
+	if goPath == "" && markdownPath == "" {
+		return fmt.Errorf("at least one of -go and -markdown is required")
+	}
+
+	c, err := catalog.Load(catalogPath)
+	if err != nil {
+		return err
+	}
+	source := filepath.Base(catalogPath)
+
+	if goPath != "" {
+		code, err := c.GenerateGo(source)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(goPath, code, 0644); err != nil {
+			return err
+		}
+	}
+	if markdownPath != "" {
+		page, err := c.GenerateMarkdown(source)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(markdownPath, page, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package events
 
 import (
 	"fmt"
+	"time"
 
 	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // AlertOpts defines the type for the functional options for publishing alerts
@@ -12,33 +14,45 @@ type AlertOpts func(*alertInst)
 
 // Alert is the interface for raising alerts
 type Alert interface {
+	Event
 	Publish(...AlertOpts) error
+	// Resolve removes the published alert. Only the stream ID option is taken into account.
+	Resolve(...AlertOpts) error
 }
 
 type alertImpl struct {
 	name     string
-	message  string
+	message  messageTemplate
 	severity connectorpb.Severity
 	state    connectorpb.State
-	registry *Registry
+	config   eventConfig
+	binding
 }
 
 var _ Alert = (*alertImpl)(nil)
 
+func (a *alertImpl) publishConfig() eventConfig {
+	return a.config
+}
+
 // NewAlert creates an alert object that can be registered with the registry
-func NewAlert(name string, message string, severity connectorpb.Severity, state connectorpb.State) Alert {
+func NewAlert(name string, message string, severity connectorpb.Severity, state connectorpb.State, opts ...EventOpts) Alert {
 	return &alertImpl{
 		name:     name,
-		message:  message,
+		message:  newMessageTemplate(name, message),
 		severity: severity,
 		state:    state,
+		config:   newEventConfig(opts),
 	}
 }
 
 type alertInst struct {
-	alert    *alertImpl
-	streamID string
-	metadata *EventMetadata
+	alert       *alertImpl
+	streamID    string
+	metadata    *EventMetadata
+	ttl         time.Duration
+	messageArgs []interface{}
+	messageData interface{}
 }
 
 // AlertWithStreamID is for publishing a stream specific alert
@@ -55,9 +69,32 @@ func AlertWithEventMetadata(metadata *EventMetadata) AlertOpts {
 	}
 }
 
+// AlertWithTTL is for publishing an alert that expires unless it is published again within the ttl.
+// It overrides the default ttl of the registry.
+func AlertWithTTL(ttl time.Duration) AlertOpts {
+	return func(inst *alertInst) {
+		inst.ttl = ttl
+	}
+}
+
+// AlertWithMessageArgs is for publishing an alert whose message is a printf-style format string
+func AlertWithMessageArgs(args ...interface{}) AlertOpts {
+	return func(inst *alertInst) {
+		inst.messageArgs = args
+	}
+}
+
+// AlertWithMessageData is for publishing an alert whose message is a text/template rendered with the data
+func AlertWithMessageData(data interface{}) AlertOpts {
+	return func(inst *alertInst) {
+		inst.messageData = data
+	}
+}
+
 // Publish publishes the alert with the provided options
 func (a *alertImpl) Publish(opts ...AlertOpts) error {
-	if a.registry == nil {
+	registry := a.registry()
+	if registry == nil {
 		return fmt.Errorf("alert not registered with the registry")
 	}
 
@@ -71,23 +108,57 @@ func (a *alertImpl) Publish(opts ...AlertOpts) error {
 	alertEvent := &connectorpb.Alert{
 		Id:       a.name,
 		StreamId: inst.streamID,
-		Message:  a.message,
+		Message:  a.message.render(inst.messageArgs, inst.messageData),
 		Severity: a.severity,
 		State:    a.state,
 	}
 
 	if inst.metadata != nil {
-		metadata, err := inst.metadata.toStruct()
-		if err != nil {
-			return err
-		}
-		alertEvent.Metadata = metadata
+		alertEvent.Metadata = inst.metadata.toStruct()
+	}
+
+	if alertEvent.Message != a.message.String() {
+		alertEvent.Metadata = withMetadataFields(alertEvent.Metadata, map[string]*structpb.Value{
+			messageTemplateProp: structpb.NewStringValue(a.message.String()),
+		})
 	}
 
-	a.registry.addEvent(alertEvent)
+	registry.addEvent(&publication{
+		event:  alertEvent,
+		ttl:    inst.ttl,
+		config: a.config,
+	})
 	return nil
 }
 
+// Resolve removes the alert published with the provided stream ID option
+func (a *alertImpl) Resolve(opts ...AlertOpts) error {
+	registry := a.registry()
+	if registry == nil {
+		return fmt.Errorf("alert not registered with the registry")
+	}
+
+	inst := &alertInst{
+		alert: a,
+	}
+	for _, opt := range opts {
+		opt(inst)
+	}
+
+	registry.removeEvent(alertKey(a.name, inst.streamID))
+	return nil
+}
+
+// Name returns the name of the alert
+func (a *alertImpl) Name() string {
+	return a.name
+}
+
+// Bind binds the alert to the registry it is registered with
+func (a *alertImpl) Bind(registry *Registry) error {
+	return a.bind(registry)
+}
+
 // String creates a stringified representation of the alert
 func (a *alertImpl) String() string {
 	return fmt.Sprintf("[name: %s][message: %s][severity: %s][state: %s]", a.name, a.message, a.severity, a.state)
@@ -0,0 +1,37 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import "time"
+
+// runInBackground calls the function at every interval until the registry is closed. The caller must hold the
+// write lock and make sure that the registry is not closed.
+func (reg *Registry) runInBackground(interval time.Duration, fn func()) {
+	reg.background.Add(1)
+	go func() {
+		defer reg.background.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-reg.done:
+				return
+			case <-ticker.C:
+				fn()
+			}
+		}
+	}()
+}
+
+// Close stops the background work of the registry, such as escalating alerts, refreshing heartbeats, removing
+// expired events and saving snapshots, and waits for it to finish
+func (reg *Registry) Close() {
+	reg.rwLock.Lock()
+	if !reg.closed {
+		reg.closed = true
+		close(reg.done)
+	}
+	reg.rwLock.Unlock()
+
+	reg.background.Wait()
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	collapsedAlertsProp  = "CollapsedAlerts"
+	collapsedStreamsProp = "CollapsedStreams"
+)
+
+// ChildAlertMode defines how GetEvents returns the child alerts of an active parent alert
+type ChildAlertMode int
+
+const (
+	// ChildAlertsVisible returns child alerts like any other alert
+	ChildAlertsVisible ChildAlertMode = iota
+	// ChildAlertsSuppressed leaves child alerts out while their parent alert is active
+	ChildAlertsSuppressed
+	// ChildAlertsCollapsed leaves child alerts out while their parent alert is active, and adds their number
+	// and streams to the metadata of the parent alert
+	ChildAlertsCollapsed
+)
+
+// String returns the name of the child alert mode
+func (m ChildAlertMode) String() string {
+	switch m {
+	case ChildAlertsVisible:
+		return "visible"
+	case ChildAlertsSuppressed:
+		return "suppressed"
+	case ChildAlertsCollapsed:
+		return "collapsed"
+	}
+	return "unknown"
+}
+
+// RegistryWithChildAlertMode sets how child alerts are returned while their parent alert is active,
+// ChildAlertsVisible by default
+func RegistryWithChildAlertMode(mode ChildAlertMode) RegistryOpts {
+	return func(reg *Registry) {
+		reg.childAlertMode = mode
+	}
+}
+
+// Correlated returns a copy of the alerts and statuses currently held by the registry with the correlation ID
+func (reg *Registry) Correlated(correlationID string) ([]*connectorpb.Alert, []*connectorpb.Status) {
+	reg.rwLock.RLock()
+	defer reg.rwLock.RUnlock()
+
+	now := reg.now()
+	var alerts []*connectorpb.Alert
+	var statuses []*connectorpb.Status
+	for _, entry := range reg.events {
+		if entry.expired(now) || metadataString(entry.event, correlationIDProp) != correlationID {
+			continue
+		}
+		switch event := entry.event.(type) {
+		case *connectorpb.Alert:
+			alerts = append(alerts, proto.Clone(event).(*connectorpb.Alert))
+		case *connectorpb.Status:
+			statuses = append(statuses, proto.Clone(event).(*connectorpb.Status))
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return eventKey(alerts[i]) < eventKey(alerts[j]) })
+	sort.Slice(statuses, func(i, j int) bool { return eventKey(statuses[i]) < eventKey(statuses[j]) })
+	return alerts, statuses
+}
+
+// activeParentKey returns the key of the active parent of the alert, if any. The parent is the alert named in the
+// metadata published for the same stream, or else for the whole connector. The caller must hold the lock.
+func (reg *Registry) activeParentKey(alert *connectorpb.Alert) (string, bool) {
+	parent := metadataString(alert, parentAlertProp)
+	if parent == "" {
+		return "", false
+	}
+	for _, key := range []string{alertKey(parent, alert.StreamId), alertKey(parent, "")} {
+		if _, ok := reg.events[key]; ok && key != eventKey(alert) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// childAlertsByParent returns the alerts with an active parent by the key of their parent, or nil when child alerts
+// are visible. The caller must hold the lock.
+func (reg *Registry) childAlertsByParent() map[string][]*connectorpb.Alert {
+	if reg.childAlertMode == ChildAlertsVisible {
+		return nil
+	}
+	children := make(map[string][]*connectorpb.Alert)
+	for _, entry := range reg.events {
+		alert, ok := entry.event.(*connectorpb.Alert)
+		if !ok {
+			continue
+		}
+		if parentKey, ok := reg.activeParentKey(alert); ok {
+			children[parentKey] = append(children[parentKey], alert)
+		}
+	}
+	return children
+}
+
+// collapse returns a copy of the parent alert with the number and the streams of its child alerts in the metadata
+func collapse(parent *connectorpb.Alert, children []*connectorpb.Alert) *connectorpb.Alert {
+	seen := make(map[string]bool)
+	streams := make([]interface{}, 0)
+	for _, child := range children {
+		if child.StreamId != "" && !seen[child.StreamId] {
+			seen[child.StreamId] = true
+			streams = append(streams, child.StreamId)
+		}
+	}
+	sort.Slice(streams, func(i, j int) bool { return streams[i].(string) < streams[j].(string) })
+
+	collapsed := proto.Clone(parent).(*connectorpb.Alert)
+	collapsed.Metadata = withMetadataFields(collapsed.Metadata, map[string]*structpb.Value{
+		collapsedAlertsProp:  structpb.NewNumberValue(float64(len(children))),
+		collapsedStreamsProp: toValue(collapsedStreamsProp, streams),
+	})
+	return collapsed
+}
+
+// metadataString returns the string property of the metadata of the event
+func metadataString(event interface{}, key string) string {
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		return event.GetMetadata().GetFields()[key].GetStringValue()
+	case *connectorpb.Status:
+		return event.GetMetadata().GetFields()[key].GetStringValue()
+	}
+	return ""
+}
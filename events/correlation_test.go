@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelation(t *testing.T) {
+	child := &EventMetadata{CorrelationID: "outage-1", ParentAlert: "brokerUnreachable"}
+
+	newRegistry := func(t *testing.T, opts ...RegistryOpts) (*Registry, Alert, Alert) {
+		reg := NewRegistry(opts...)
+		parent := NewAlert("brokerUnreachable", "broker is unreachable", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		symptom := NewAlert("unableToPublish", "unable to publish", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterAlert(parent))
+		require.NoError(t, reg.RegisterAlert(symptom))
+
+		require.NoError(t, parent.Publish(AlertWithEventMetadata(&EventMetadata{CorrelationID: "outage-1"})))
+		require.NoError(t, symptom.Publish(AlertWithStreamID("s1"), AlertWithEventMetadata(child)))
+		require.NoError(t, symptom.Publish(AlertWithStreamID("s2"), AlertWithEventMetadata(child)))
+		return reg, parent, symptom
+	}
+
+	alertIDs := func(payloads []*connectorpb.EventPayload) []string {
+		var ids []string
+		for _, p := range payloads {
+			ids = append(ids, p.GetAlert().GetId()+"/"+p.GetAlert().GetStreamId())
+		}
+		return ids
+	}
+
+	t.Run("the relationship is carried in the metadata", func(t *testing.T) {
+		reg, _, _ := newRegistry(t)
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 3)
+		fields := payloads[1].GetAlert().GetMetadata().GetFields()
+		assert.Equal(t, "outage-1", fields[correlationIDProp].GetStringValue())
+		assert.Equal(t, "brokerUnreachable", fields[parentAlertProp].GetStringValue())
+	})
+
+	t.Run("correlated events are looked up by correlation ID", func(t *testing.T) {
+		reg, _, _ := newRegistry(t)
+		alerts, statuses := reg.Correlated("outage-1")
+		require.Len(t, alerts, 3)
+		assert.Equal(t, "brokerUnreachable", alerts[0].GetId())
+		assert.Empty(t, statuses)
+
+		alerts, _ = reg.Correlated("outage-2")
+		assert.Empty(t, alerts)
+	})
+
+	t.Run("child alerts are suppressed while the parent is active", func(t *testing.T) {
+		reg, parent, _ := newRegistry(t, RegistryWithChildAlertMode(ChildAlertsSuppressed))
+		assert.Equal(t, []string{"brokerUnreachable/"}, alertIDs(getEventPayloads(t, reg)))
+
+		require.NoError(t, parent.Resolve())
+		assert.ElementsMatch(t, []string{"unableToPublish/s1", "unableToPublish/s2"}, alertIDs(getEventPayloads(t, reg)))
+	})
+
+	t.Run("child alerts are collapsed into the parent", func(t *testing.T) {
+		reg, _, _ := newRegistry(t, RegistryWithChildAlertMode(ChildAlertsCollapsed))
+		payloads := getEventPayloads(t, reg)
+		require.Equal(t, []string{"brokerUnreachable/"}, alertIDs(payloads))
+
+		fields := payloads[0].GetAlert().GetMetadata().GetFields()
+		assert.Equal(t, float64(2), fields[collapsedAlertsProp].GetNumberValue())
+		streams := fields[collapsedStreamsProp].GetListValue().AsSlice()
+		assert.Equal(t, []interface{}{"s1", "s2"}, streams)
+
+		alerts, _ := reg.Correlated("outage-1")
+		assert.NotContains(t, alerts[0].GetMetadata().GetFields(), collapsedAlertsProp)
+	})
+
+	t.Run("a parent published for a stream only holds back the alerts of that stream", func(t *testing.T) {
+		reg, parent, _ := newRegistry(t, RegistryWithChildAlertMode(ChildAlertsSuppressed))
+		require.NoError(t, parent.Resolve())
+		require.NoError(t, parent.Publish(AlertWithStreamID("s1")))
+		assert.Equal(t, []string{"brokerUnreachable/s1", "unableToPublish/s2"}, alertIDs(getEventPayloads(t, reg)))
+	})
+
+	t.Run("child alerts held back in delta mode are delivered with the removal of the parent", func(t *testing.T) {
+		reg, parent, _ := newRegistry(t, RegistryWithChildAlertMode(ChildAlertsSuppressed), RegistryWithDeliveryMode(DeliveryDelta))
+		assert.Len(t, getEventPayloads(t, reg), 1)
+		assert.Empty(t, getEventPayloads(t, reg))
+
+		require.NoError(t, parent.Resolve())
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 3)
+		assert.Equal(t, []string{"brokerUnreachable/"}, alertIDs(payloads[:1]))
+		assert.Contains(t, payloads[0].GetAlert().GetMetadata().GetFields(), resolvedAtProp)
+		assert.ElementsMatch(t, []string{"unableToPublish/s1", "unableToPublish/s2"}, alertIDs(payloads[1:]))
+	})
+
+	t.Run("child alert modes have names", func(t *testing.T) {
+		assert.Equal(t, "visible", ChildAlertsVisible.String())
+		assert.Equal(t, "collapsed", ChildAlertsCollapsed.String())
+	})
+}
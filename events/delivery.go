@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const resolvedAtProp = "ResolvedAt"
+
+// DeliveryMode controls which events GetEvents returns on each scrape
+type DeliveryMode int
+
+const (
+	// DeliverySticky returns an event on every scrape until it is resolved, cleared or expires
+	DeliverySticky DeliveryMode = iota
+	// DeliveryDrain returns an event on the next scrape only and then removes it, e.g. for informational alerts
+	DeliveryDrain
+	// DeliveryDelta returns an event only on the first scrape after it has been published or changed; republishing
+	// an unchanged event does not return it again. Once a
+	// delivered event is resolved, cleared or expires, the next scrape returns it one last time with the time of
+	// its removal in the `ResolvedAt` metadata property.
+	DeliveryDelta
+)
+
+// String returns the name of the delivery mode
+func (m DeliveryMode) String() string {
+	switch m {
+	case DeliverySticky:
+		return "sticky"
+	case DeliveryDrain:
+		return "drain"
+	case DeliveryDelta:
+		return "delta"
+	}
+	return fmt.Sprintf("DeliveryMode(%d)", int(m))
+}
+
+// RegistryWithDeliveryMode sets the delivery mode of the events that do not set their own. Events are sticky by default.
+func RegistryWithDeliveryMode(mode DeliveryMode) RegistryOpts {
+	return func(reg *Registry) {
+		reg.delivery = mode
+	}
+}
+
+// EventWithDeliveryMode sets the delivery mode of the event, overriding the one of the registry
+func EventWithDeliveryMode(mode DeliveryMode) EventOpts {
+	return func(cfg *eventConfig) {
+		cfg.delivery = &mode
+	}
+}
+
+// deliveryMode returns the delivery mode of a publication
+func (reg *Registry) deliveryMode(pub *publication) DeliveryMode {
+	if pub.config.delivery != nil {
+		return *pub.config.delivery
+	}
+	return reg.delivery
+}
+
+// deliverEvents returns the events due on a scrape according to their delivery mode, ordered by priority and
+// capped to the limits of the registry. Delivered drain events are removed, and delivered delta events are not
+// returned again until they change. Events left out by the limits, and child alerts held back while their parent
+// alert is active, are delivered on a later scrape.
+func (reg *Registry) deliverEvents() []interface{} {
+	reg.rwLock.Lock()
+	now := reg.now()
+	children := reg.childAlertsByParent()
+	hidden := make(map[string]bool)
+	for _, list := range children {
+		for _, child := range list {
+			hidden[eventKey(child)] = true
+		}
+	}
+	var due []dueEvent
+	for key, entry := range reg.tombstones {
+		due = append(due, dueEvent{key: key, entry: entry, event: entry.event, tombstone: true})
+	}
+	for key, entry := range reg.events {
+		if hidden[key] || (entry.delivery == DeliveryDelta && entry.delivered) {
+			continue
+		}
+		event := entry.event
+		if alert, ok := event.(*connectorpb.Alert); ok && reg.childAlertMode == ChildAlertsCollapsed && len(children[key]) > 0 {
+			event = collapse(alert, children[key])
+		}
+		due = append(due, dueEvent{key: key, entry: entry, event: event})
+	}
+	sortDueEvents(due)
+	included, truncated := reg.limitEvents(due, now)
+
+	events := make([]interface{}, 0, included+1)
+	var drained []EventChange
+	for _, d := range due[:included] {
+		if d.tombstone {
+			delete(reg.tombstones, d.key)
+		} else if d.entry.delivery == DeliveryDrain {
+			delete(reg.events, d.key)
+			drained = append(drained, EventChange{
+				Type:  EventResolved,
+				Time:  now,
+				Event: d.entry.event,
+			})
+		}
+		d.entry.delivered = true
+		events = append(events, d.event)
+	}
+	if truncated != nil {
+		events = append(events, truncated)
+	}
+	reg.queueChanges(drained...)
+	reg.rwLock.Unlock()
+
+	reg.notify()
+	return events
+}
+
+// buryEvent keeps a tombstone of a removed delta event that has been delivered, so that the next scrape reports
+// its removal. The caller must hold the write lock.
+func (reg *Registry) buryEvent(key string, entry *eventEntry, now time.Time) {
+	if entry.delivery != DeliveryDelta || !entry.delivered {
+		return
+	}
+	resolved := map[string]*structpb.Value{
+		resolvedAtProp: structpb.NewStringValue(now.UTC().Format(time.RFC3339Nano)),
+	}
+	var event interface{}
+	switch published := entry.event.(type) {
+	case *connectorpb.Alert:
+		alert := proto.Clone(published).(*connectorpb.Alert)
+		alert.Metadata = withMetadataFields(alert.Metadata, resolved)
+		event = alert
+	case *connectorpb.Status:
+		status := proto.Clone(published).(*connectorpb.Status)
+		status.Metadata = withMetadataFields(status.Metadata, resolved)
+		event = status
+	default:
+		return
+	}
+	reg.tombstones[key] = &eventEntry{
+		event:     event,
+		firstSeen: entry.firstSeen,
+		lastSeen:  now,
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliveryModes(t *testing.T) {
+	newStatus := func(opts ...EventOpts) Status {
+		return NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY, opts...)
+	}
+
+	t.Run("drain events are returned once and then removed", func(t *testing.T) {
+		sink := &recordingSink{}
+		reg := NewRegistry(RegistryWithSinks(sink))
+		sticky := newStatus()
+		info := NewAlert("configReloaded", "configuration reloaded", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY, EventWithDeliveryMode(DeliveryDrain))
+		require.NoError(t, reg.RegisterStatus(sticky))
+		require.NoError(t, reg.RegisterAlert(info))
+
+		require.NoError(t, sticky.Publish())
+		require.NoError(t, info.Publish())
+		assert.Len(t, getEventPayloads(t, reg), 2)
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.NotNil(t, payloads[0].GetStatus())
+		assert.Equal(t, []ChangeType{EventPublished, EventPublished, EventResolved}, sink.types())
+	})
+
+	t.Run("delta events are returned only after they change", func(t *testing.T) {
+		reg := NewRegistry(RegistryWithDeliveryMode(DeliveryDelta))
+		status := newStatus()
+		sticky := NewStatus("lagging", "stream is lagging", connectorpb.State_STATE_UNHEALTHY, EventWithDeliveryMode(DeliverySticky))
+		require.NoError(t, reg.RegisterStatus(status))
+		require.NoError(t, reg.RegisterStatus(sticky))
+
+		require.NoError(t, status.Publish())
+		require.NoError(t, sticky.Publish())
+		assert.Len(t, getEventPayloads(t, reg), 2)
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.Equal(t, "lagging", payloads[0].GetStatus().GetId())
+
+		require.NoError(t, status.Publish())
+		assert.Len(t, getEventPayloads(t, reg), 1, "an unchanged republication is not returned again")
+
+		require.NoError(t, status.Publish(StatusWithState(connectorpb.State_STATE_HEALTHY)))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+		assert.Len(t, reg.Statuses(), 2)
+	})
+
+	t.Run("removed delta events are returned once more with their removal time", func(t *testing.T) {
+		reg := NewRegistry(RegistryWithDeliveryMode(DeliveryDelta))
+		status := newStatus()
+		unseen := NewStatus("lagging", "stream is lagging", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterStatus(status))
+		require.NoError(t, reg.RegisterStatus(unseen))
+
+		require.NoError(t, status.Publish())
+		require.Len(t, getEventPayloads(t, reg), 1)
+		require.NoError(t, unseen.Publish())
+		require.NoError(t, unseen.Clear())
+		require.NoError(t, status.Clear())
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.Equal(t, "unableToContactDB", payloads[0].GetStatus().GetId())
+		assert.Contains(t, payloads[0].GetStatus().GetMetadata().GetFields(), resolvedAtProp)
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+
+	t.Run("republishing a removed delta event replaces its tombstone", func(t *testing.T) {
+		reg := NewRegistry(RegistryWithDeliveryMode(DeliveryDelta))
+		status := newStatus()
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, status.Publish())
+		require.Len(t, getEventPayloads(t, reg), 1)
+		require.NoError(t, status.Clear())
+		require.NoError(t, status.Publish())
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.NotContains(t, payloads[0].GetStatus().GetMetadata().GetFields(), resolvedAtProp)
+	})
+}
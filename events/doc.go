@@ -10,7 +10,7 @@ A registry can be instantiated with the NewRegistry function e.g.
 
 Registering an alert requires instantiating an alert with `NewAlert` and registering it with the registry e.g.
 	unableToFetchDataAlert := NewAlert("unableToFetchData", "unable to fetch the data required to stream", connector.Severity_SEVERITY_CRITICAL, connector.State_STATE_FAILED)
-	registry.RegisterAlert(unableToFetchDataAlert)
+	err := registry.RegisterAlert(unableToFetchDataAlert)
 
 Raising the alert requires calling the `Publish` method on the alert e.g.
 	unableToFetchDataAlert.Publish()
@@ -19,13 +19,20 @@ Raising the alert requires calling the `Publish` method on the alert e.g.
 
 Registering a status requires instantiating an alert with `NewStatus` and registering it with the registry e.g.
 	unableToContactDB := NewStatus("unableToContactDB", "unable to contact the database required to stream data", connector.State_STATE_UNHEALTHY)
-	registry.RegisterStatus(unableToContactDB)
+	err := registry.RegisterStatus(unableToContactDB)
 
 Updating the status requires calling the `Publish` method on the status e.g.
 	unableToContactDB.Publish()
 	unableToContactDB.Publish(StatusWithStreamID("..."))
 	unableToContactDB.Publish(StatusWithStreamID("..."), AlertWithMetadata(metadata))
 
+An alert stays active until it is resolved, and a status until it is cleared e.g.
+	unableToFetchDataAlert.Resolve(AlertWithStreamID("..."))
+	unableToContactDB.Clear(StatusWithStreamID("..."))
+
+Expiry, delivery modes, escalation, correlation of child alerts and sinks are configured with the RegistryOpts of the
+registry and the EventOpts of each event. See the examples for their usage.
+
 Using the registry in the grpc server implementing the contract is as simple as embedding the registry object. e.g.
 This makes sure that your server fulfils the `GetEvents` method needed for periodically scraping the events.
 	type connector struct {
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"errors"
+	"sync"
+)
+
+// AlertError is an error bound to the alert that has to be published when it occurs
+type AlertError struct {
+	Err   error
+	Alert Alert
+	// Opts are the options the alert is published and resolved with, e.g. the stream ID
+	Opts []AlertOpts
+}
+
+// WithAlert binds the error to the alert published with the options, e.g. the stream ID. It returns nil for a nil error.
+func WithAlert(err error, alert Alert, opts ...AlertOpts) error {
+	if err == nil {
+		return nil
+	}
+	return &AlertError{Err: err, Alert: alert, Opts: opts}
+}
+
+// Error returns the message of the bound error
+func (e *AlertError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the bound error
+func (e *AlertError) Unwrap() error {
+	return e.Err
+}
+
+// PublishError publishes the alert bound to the error, if any, with metadata created from the error.
+// Errors that are not bound to an alert are ignored.
+func PublishError(err error) error {
+	var alertErr *AlertError
+	if !errors.As(err, &alertErr) || alertErr.Alert == nil {
+		return nil
+	}
+	return publishErrorAlert(err, alertErr.Alert, alertErr.Opts)
+}
+
+func publishErrorAlert(err error, alert Alert, opts []AlertOpts) error {
+	publishOpts := append([]AlertOpts{AlertWithEventMetadata(EventMetadataFromError(err))}, opts...)
+	return alert.Publish(publishOpts...)
+}
+
+// Guard publishes alerts for the errors of a recurring call, and resolves them once the call succeeds again
+type Guard struct {
+	alert Alert
+	opts  []AlertOpts
+	// active holds the published alerts by alert name and stream ID
+	active map[string]*AlertError
+	lock   sync.Mutex
+}
+
+// NewGuard creates a guard that publishes the alert with the options for errors that are not bound to another alert.
+// The alert can be nil for guarding calls whose errors are all bound with WithAlert.
+func NewGuard(alert Alert, opts ...AlertOpts) *Guard {
+	return &Guard{
+		alert:  alert,
+		opts:   opts,
+		active: make(map[string]*AlertError),
+	}
+}
+
+// Check publishes the alert for the error, or resolves the alerts published for the previous errors if it is nil.
+// The error is returned unchanged, so that Check can wrap the return value of a call.
+func (g *Guard) Check(err error) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if err == nil {
+		for key, active := range g.active {
+			if resolveErr := active.Alert.Resolve(active.Opts...); resolveErr != nil {
+				return resolveErr
+			}
+			delete(g.active, key)
+		}
+		return nil
+	}
+
+	alert, opts := g.alert, g.opts
+	var alertErr *AlertError
+	if errors.As(err, &alertErr) && alertErr.Alert != nil {
+		alert, opts = alertErr.Alert, alertErr.Opts
+	}
+	if alert == nil {
+		return err
+	}
+	if publishErr := publishErrorAlert(err, alert, opts); publishErr != nil {
+		return publishErr
+	}
+	g.active[alertKey(alert.Name(), optsStreamID(opts))] = &AlertError{Err: err, Alert: alert, Opts: opts}
+	return err
+}
+
+// optsStreamID returns the stream ID set by the alert options
+func optsStreamID(opts []AlertOpts) string {
+	inst := &alertInst{}
+	for _, opt := range opts {
+		opt(inst)
+	}
+	return inst.streamID
+}
+
+// Run calls the function and checks its error
+func (g *Guard) Run(fn func() error) error {
+	return g.Check(fn())
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorAlerts(t *testing.T) {
+	newRegistry := func(t *testing.T) (*Registry, Alert, Alert) {
+		reg := NewRegistry()
+		fetch := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		decode := NewAlert("unableToDecodeData", "unable to decode data", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterAlert(fetch))
+		require.NoError(t, reg.RegisterAlert(decode))
+		return reg, fetch, decode
+	}
+	errTimeout := errors.New("timeout")
+
+	t.Run("an error bound to an alert publishes it with the error metadata", func(t *testing.T) {
+		reg, fetch, _ := newRegistry(t)
+
+		err := WithAlert(fmt.Errorf("unable to fetch: %w", errTimeout), fetch, AlertWithStreamID("stream1"))
+		assert.ErrorIs(t, err, errTimeout)
+		require.NoError(t, PublishError(fmt.Errorf("poll: %w", err)))
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		alert := payloads[0].GetAlert()
+		assert.Equal(t, "stream1", alert.GetStreamId())
+		assert.Equal(t, "poll: unable to fetch: timeout", alert.GetMetadata().GetFields()[errorMessageProp].GetStringValue())
+		assert.Equal(t, "*fmt.wrapError", alert.GetMetadata().GetFields()[errorTypeProp].GetStringValue())
+		causes := alert.GetMetadata().GetFields()[errorCausesProp].GetListValue().GetValues()
+		require.Len(t, causes, 2)
+		assert.Equal(t, "*fmt.wrapError", causes[0].GetStructValue().GetFields()["Type"].GetStringValue())
+		assert.Equal(t, "*errors.errorString", causes[1].GetStructValue().GetFields()["Type"].GetStringValue())
+
+		require.NoError(t, PublishError(WithAlert(errTimeout, fetch)))
+		fields := getEventPayloads(t, reg)[0].GetAlert().GetMetadata().GetFields()
+		assert.Equal(t, "*errors.errorString", fields[errorTypeProp].GetStringValue())
+		assert.NotContains(t, fields, errorCausesProp)
+
+		assert.NoError(t, PublishError(errTimeout))
+		assert.Nil(t, WithAlert(nil, fetch))
+	})
+
+	t.Run("a guard resolves its alerts once the call succeeds", func(t *testing.T) {
+		reg, fetch, decode := newRegistry(t)
+		guard := NewGuard(fetch, AlertWithStreamID("stream1"))
+
+		assert.ErrorIs(t, guard.Run(func() error { return errTimeout }), errTimeout)
+		assert.Error(t, guard.Check(WithAlert(errors.New("bad json"), decode, AlertWithStreamID("stream1"))))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+
+		require.NoError(t, guard.Run(func() error { return nil }))
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+
+	t.Run("a guard without an alert only handles bound errors", func(t *testing.T) {
+		reg, _, decode := newRegistry(t)
+		guard := NewGuard(nil)
+
+		assert.ErrorIs(t, guard.Check(errTimeout), errTimeout)
+		assert.Empty(t, getEventPayloads(t, reg))
+
+		assert.Error(t, guard.Check(WithAlert(errTimeout, decode)))
+		assert.Len(t, getEventPayloads(t, reg), 1)
+	})
+
+	t.Run("a guard resolves the alerts of every stream", func(t *testing.T) {
+		reg, fetch, _ := newRegistry(t)
+		guard := NewGuard(nil)
+
+		assert.Error(t, guard.Check(WithAlert(errTimeout, fetch, AlertWithStreamID("s1"))))
+		assert.Error(t, guard.Check(WithAlert(errTimeout, fetch, AlertWithStreamID("s2"))))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+
+		require.NoError(t, guard.Check(nil))
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+}
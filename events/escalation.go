@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	defaultEscalationInterval = 10 * time.Second
+
+	escalatedFromProp = "EscalatedFrom"
+	escalatedAtProp   = "EscalatedAt"
+	escalationsProp   = "Escalations"
+)
+
+// EventWithEscalation raises the severity of an alert by one level, from INFO to WARNING to CRITICAL, each time it
+// stays active for the duration. The escalation holds until the alert is resolved, even if it is published again
+// with its original severity.
+func EventWithEscalation(after time.Duration) EventOpts {
+	return func(cfg *eventConfig) {
+		cfg.escalateAfter = after
+	}
+}
+
+// RegistryWithEscalationInterval sets how often the registry checks whether alerts have to be escalated.
+// Non-positive intervals are ignored.
+func RegistryWithEscalationInterval(interval time.Duration) RegistryOpts {
+	return func(reg *Registry) {
+		if interval > 0 {
+			reg.escalationInterval = interval
+		}
+	}
+}
+
+// escalation is the escalation state of an active alert
+type escalation struct {
+	after    time.Duration
+	since    time.Time
+	from     connectorpb.Severity
+	severity connectorpb.Severity
+	at       time.Time
+	count    int
+}
+
+// restoreEscalation returns the escalation state of an alert active since the given time, resuming the escalations
+// recorded in its metadata, e.g. for an alert restored from a snapshot
+func restoreEscalation(alert *connectorpb.Alert, after time.Duration, since time.Time) *escalation {
+	e := &escalation{after: after, since: since}
+	fields := alert.GetMetadata().GetFields()
+	count := int(fields[escalationsProp].GetNumberValue())
+	at, err := time.Parse(time.RFC3339Nano, fields[escalatedAtProp].GetStringValue())
+	if count <= 0 || err != nil {
+		return e
+	}
+	e.from = connectorpb.Severity(connectorpb.Severity_value[fields[escalatedFromProp].GetStringValue()])
+	e.severity = alert.Severity
+	e.at = at
+	e.since = at
+	e.count = count
+	return e
+}
+
+// apply raises the severity of the alert to the escalated one and records the escalation in its metadata
+func (e *escalation) apply(alert *connectorpb.Alert) {
+	if e.count == 0 || alert.Severity >= e.severity {
+		return
+	}
+	alert.Severity = e.severity
+	alert.Metadata = withMetadataFields(alert.Metadata, map[string]*structpb.Value{
+		escalatedFromProp: structpb.NewStringValue(e.from.String()),
+		escalatedAtProp:   structpb.NewStringValue(e.at.UTC().Format(time.RFC3339Nano)),
+		escalationsProp:   structpb.NewNumberValue(float64(e.count)),
+	})
+}
+
+// startEscalation starts the background ticker escalating alerts unless it is running or the registry is closed.
+// The caller must hold the write lock.
+func (reg *Registry) startEscalation() {
+	if reg.escalating || reg.closed {
+		return
+	}
+	reg.escalating = true
+	reg.runInBackground(reg.escalationInterval, reg.escalateEvents)
+}
+
+// escalateEvents raises the severity of the alerts that have been active for longer than their escalation duration
+func (reg *Registry) escalateEvents() {
+	reg.rwLock.Lock()
+	now := reg.now()
+	var changes []EventChange
+	for key, entry := range reg.events {
+		esc := entry.escalation
+		alert, ok := entry.event.(*connectorpb.Alert)
+		if !ok || esc == nil || entry.expired(now) || alert.Severity >= connectorpb.Severity_SEVERITY_CRITICAL {
+			continue
+		}
+		if now.Sub(esc.since) < esc.after {
+			continue
+		}
+
+		if esc.count == 0 {
+			esc.from = alert.Severity
+		}
+		esc.severity = alert.Severity + 1
+		esc.since = now
+		esc.at = now
+		esc.count++
+
+		escalated := proto.Clone(alert).(*connectorpb.Alert)
+		esc.apply(escalated)
+		entry.event = escalated
+		entry.delivered = false
+
+		change := EventChange{
+			Type:     EventUpdated,
+			Time:     now,
+			Event:    escalated,
+			Previous: alert,
+		}
+		reg.recordOccurrence(key, escalated, now)
+		reg.metrics.observe(change)
+		changes = append(changes, change)
+	}
+	reg.queueChanges(changes...)
+	reg.rwLock.Unlock()
+
+	reg.notify()
+}
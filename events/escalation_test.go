@@ -0,0 +1,116 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEscalation(t *testing.T) {
+	newAlert := func() Alert {
+		return NewAlert("slowResponses", "slow responses", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY, EventWithEscalation(time.Minute))
+	}
+	currentAlert := func(t *testing.T, reg *Registry) *connectorpb.Alert {
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		return payloads[0].GetAlert()
+	}
+
+	t.Run("long running alerts are escalated up to critical", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		sink := &recordingSink{}
+		reg := NewRegistry(RegistryWithSinks(sink))
+		defer reg.Close()
+		reg.now = clock.now
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish())
+		clock.advance(30 * time.Second)
+		reg.escalateEvents()
+		assert.Equal(t, connectorpb.Severity_SEVERITY_INFO, currentAlert(t, reg).GetSeverity())
+
+		clock.advance(30 * time.Second)
+		reg.escalateEvents()
+		escalated := currentAlert(t, reg)
+		assert.Equal(t, connectorpb.Severity_SEVERITY_WARNING, escalated.GetSeverity())
+		fields := escalated.GetMetadata().GetFields()
+		assert.Equal(t, connectorpb.Severity_SEVERITY_INFO.String(), fields[escalatedFromProp].GetStringValue())
+		assert.Equal(t, float64(1), fields[escalationsProp].GetNumberValue())
+
+		require.NoError(t, alert.Publish())
+		assert.Equal(t, connectorpb.Severity_SEVERITY_WARNING, currentAlert(t, reg).GetSeverity())
+
+		for i := 0; i < 3; i++ {
+			clock.advance(time.Minute)
+			reg.escalateEvents()
+		}
+		assert.Equal(t, connectorpb.Severity_SEVERITY_CRITICAL, currentAlert(t, reg).GetSeverity())
+		assert.Equal(t, []ChangeType{EventPublished, EventUpdated, EventUpdated, EventUpdated}, sink.types())
+	})
+
+	t.Run("resolving an alert resets its escalation", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry()
+		defer reg.Close()
+		reg.now = clock.now
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish())
+		clock.advance(time.Minute)
+		reg.escalateEvents()
+		require.NoError(t, alert.Resolve())
+		require.NoError(t, alert.Publish())
+		assert.Equal(t, connectorpb.Severity_SEVERITY_INFO, currentAlert(t, reg).GetSeverity())
+	})
+
+	t.Run("the background ticker escalates alerts until the registry is closed", func(t *testing.T) {
+		reg := NewRegistry(RegistryWithEscalationInterval(time.Millisecond))
+		alert := NewAlert("slowResponses", "slow responses", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY, EventWithEscalation(time.Millisecond))
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish())
+		assert.Eventually(t, func() bool {
+			return currentAlert(t, reg).GetSeverity() == connectorpb.Severity_SEVERITY_CRITICAL
+		}, time.Second, time.Millisecond)
+
+		reg.Close()
+		reg.Close()
+		require.NoError(t, alert.Resolve())
+		require.NoError(t, alert.Publish())
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, connectorpb.Severity_SEVERITY_INFO, currentAlert(t, reg).GetSeverity())
+	})
+
+	t.Run("non-positive escalation intervals are ignored", func(t *testing.T) {
+		reg := NewRegistry(RegistryWithEscalationInterval(0))
+		defer reg.Close()
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		assert.Equal(t, defaultEscalationInterval, reg.escalationInterval)
+		assert.NotPanics(t, func() { require.NoError(t, alert.Publish()) })
+	})
+
+	t.Run("escalations recorded in the metadata are resumed", func(t *testing.T) {
+		escalatedAt := time.Unix(60, 0)
+		alert := &connectorpb.Alert{Severity: connectorpb.Severity_SEVERITY_INFO}
+		esc := &escalation{from: connectorpb.Severity_SEVERITY_INFO, severity: connectorpb.Severity_SEVERITY_WARNING, at: escalatedAt, count: 1}
+		esc.apply(alert)
+
+		restored := restoreEscalation(alert, time.Minute, time.Unix(0, 0))
+		assert.Equal(t, connectorpb.Severity_SEVERITY_INFO, restored.from)
+		assert.Equal(t, connectorpb.Severity_SEVERITY_WARNING, restored.severity)
+		assert.Equal(t, 1, restored.count)
+		assert.True(t, escalatedAt.Equal(restored.since))
+
+		fresh := restoreEscalation(&connectorpb.Alert{}, time.Minute, time.Unix(0, 0))
+		assert.Equal(t, 0, fresh.count)
+		assert.True(t, time.Unix(0, 0).Equal(fresh.since))
+	})
+}
@@ -2,34 +2,86 @@
 package events
 
 import (
-	"github.com/golang/glog"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
 	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-// EventMetadata is a mechanism for adding extra arbitrary properties to an event as metadata
-type EventMetadata struct {
-	ErrorMessage string
-	StreamID     string
-	Extra        map[string]interface{}
+// ErrDuplicateEvent is returned when registering an event with a name that is already registered
+var ErrDuplicateEvent = errors.New("event already registered")
+
+// Event is the interface an alert or a status implements for being registered with a registry.
+// Implementations other than the ones created by NewAlert and NewStatus, e.g. wrappers or fakes,
+// can be registered as well.
+type Event interface {
+	// Name returns the name of the event, which is the ID of its publications and unique per registry
+	Name() string
+	// Bind is called when the event is registered with the registry it has to publish into
+	Bind(registry *Registry) error
+}
+
+// binding ties an alert or a status to the registry it has been registered with
+type binding struct {
+	lock sync.RWMutex
+	reg  *Registry
+}
+
+func (b *binding) bind(reg *Registry) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.reg != nil && b.reg != reg {
+		return fmt.Errorf("event is registered with another registry")
+	}
+	b.reg = reg
+	return nil
+}
+
+func (b *binding) registry() *Registry {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.reg
 }
 
 const (
-	errorMessageProp = "ErrorMessage"
-	streamIDProp     = "StreamID"
-	extraMessageProp = "ExtraMessage"
+	// properties managed by the registry
+	lastSeenAtProp      = "LastSeenAt"
+	occurrencesProp     = "Occurrences"
+	messageTemplateProp = "MessageTemplate"
 )
 
-func (em *EventMetadata) toStruct() (*structpb.Struct, error) {
-	m := make(map[string]interface{})
-	m[errorMessageProp] = em.ErrorMessage
-	m[streamIDProp] = em.StreamID
-	m[extraMessageProp] = em.Extra
-
-	metadata, err := structpb.NewStruct(m)
-	if err != nil {
-		glog.Errorf("unable convert properties to metadata proto structure")
-		return nil, err
+// withMetadataFields sets the fields on the metadata of an event, creating the metadata if needed
+func withMetadataFields(metadata *structpb.Struct, fields map[string]*structpb.Value) *structpb.Struct {
+	if metadata == nil {
+		metadata = &structpb.Struct{}
+	}
+	if metadata.Fields == nil {
+		metadata.Fields = make(map[string]*structpb.Value, len(fields))
 	}
+	for key, value := range fields {
+		metadata.Fields[key] = value
+	}
+	return metadata
+}
 
-	return metadata, nil
+// stampEvent sets the creation time, the last seen time and the number of occurrences on the event of the entry
+func stampEvent(entry *eventEntry) {
+	fields := map[string]*structpb.Value{
+		lastSeenAtProp:  structpb.NewStringValue(entry.lastSeen.UTC().Format(time.RFC3339Nano)),
+		occurrencesProp: structpb.NewNumberValue(float64(entry.occurrences)),
+	}
+	switch event := entry.event.(type) {
+	case *connectorpb.Alert:
+		event.CreatedAt = timestamppb.New(entry.firstSeen)
+		event.Metadata = withMetadataFields(event.Metadata, fields)
+	case *connectorpb.Status:
+		event.CreatedAt = timestamppb.New(entry.firstSeen)
+		event.Metadata = withMetadataFields(event.Metadata, fields)
+	}
 }
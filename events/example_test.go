@@ -0,0 +1,296 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func printEvents(registry *events.Registry) {
+	resp, err := registry.GetEvents(context.Background(), &connectorpb.GetEventsRequest{})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, payload := range resp.GetEventPayloads() {
+		if alert := payload.GetAlert(); alert != nil {
+			fmt.Printf("alert %s %s: %s\n", alert.GetId(), alert.GetSeverity(), alert.GetMessage())
+		} else {
+			status := payload.GetStatus()
+			fmt.Printf("status %s %s: %s\n", status.GetId(), status.GetState(), status.GetMessage())
+		}
+	}
+}
+
+func ExampleRegistry_RegisterAlert() {
+	registry := events.NewRegistry()
+	unableToFetchData := events.NewAlert("unableToFetchData", "unable to fetch the data required to stream", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+
+	// registering a second event with the same name fails
+	duplicate := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+	err := registry.RegisterAlert(duplicate)
+	fmt.Println(errors.Is(err, events.ErrDuplicateEvent))
+	// Output: true
+}
+
+func ExampleNewAlert() {
+	registry := events.NewRegistry()
+	unableToConnect := events.NewAlert("unableToConnect", "unable to connect to {{.Host}}", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	lagging := events.NewStatus("lagging", "stream is %d messages behind", connectorpb.State_STATE_UNHEALTHY)
+	if err := registry.RegisterAlert(unableToConnect); err != nil {
+		fmt.Println(err)
+	}
+	if err := registry.RegisterStatus(lagging); err != nil {
+		fmt.Println(err)
+	}
+
+	_ = unableToConnect.Publish(events.AlertWithMessageData(map[string]string{"Host": "broker:4222"}))
+	_ = lagging.Publish(events.StatusWithMessageArgs(42))
+	printEvents(registry)
+	// Output:
+	// alert unableToConnect SEVERITY_CRITICAL: unable to connect to broker:4222
+	// status lagging STATE_UNHEALTHY: stream is 42 messages behind
+}
+
+func ExampleStatusWithState() {
+	registry := events.NewRegistry()
+	dbHealth := events.NewStatus("dbHealth", "database health", connectorpb.State_STATE_HEALTHY)
+	if err := registry.RegisterStatus(dbHealth); err != nil {
+		fmt.Println(err)
+	}
+
+	_ = dbHealth.Publish(events.StatusWithState(connectorpb.State_STATE_UNHEALTHY))
+	printEvents(registry)
+	// Output: status dbHealth STATE_UNHEALTHY: database health
+}
+
+func ExampleEventWithMinHoldTime() {
+	registry := events.NewRegistry(events.RegistryWithMetrics(prometheus.NewRegistry()))
+	dbHealth := events.NewStatus("dbHealth", "database health", connectorpb.State_STATE_HEALTHY, events.EventWithMinHoldTime(time.Minute), events.EventWithHysteresis(3))
+	unableToFetchData := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED, events.EventWithRateLimit(0.1, 1))
+	if err := registry.RegisterStatus(dbHealth); err != nil {
+		fmt.Println(err)
+	}
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func ExampleEventMetadataFromError() {
+	registry := events.NewRegistry()
+	unableToFetchData := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+
+	err := fmt.Errorf("fetching data: %w", os.ErrDeadlineExceeded)
+	_ = unableToFetchData.Publish(events.AlertWithEventMetadata(events.EventMetadataFromError(err)))
+}
+
+func ExampleNewGuard() {
+	registry := events.NewRegistry()
+	unableToFetchData := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+
+	// the alert is published while fetching fails and resolved once it succeeds again
+	guard := events.NewGuard(unableToFetchData, events.AlertWithStreamID("s1"))
+	_ = guard.Run(func() error { return errors.New("connection refused") })
+	printEvents(registry)
+	_ = guard.Run(func() error { return nil })
+	printEvents(registry)
+	// Output: alert unableToFetchData SEVERITY_CRITICAL: ...
+}
+
+func ExampleWithAlert() {
+	registry := events.NewRegistry()
+	unableToDecodeData := events.NewAlert("unableToDecodeData", "...", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+	if err := registry.RegisterAlert(unableToDecodeData); err != nil {
+		fmt.Println(err)
+	}
+
+	decode := func() error {
+		return events.WithAlert(errors.New("unexpected end of input"), unableToDecodeData, events.AlertWithStreamID("s1"))
+	}
+	_ = events.PublishError(decode())
+	printEvents(registry)
+	// Output: alert unableToDecodeData SEVERITY_WARNING: ...
+}
+
+func ExampleNewLifecycle() {
+	registry := events.NewRegistry()
+	connectorStatus := events.NewStatus("connectorStatus", "connector state", connectorpb.State_STATE_PROVISIONING)
+	if err := registry.RegisterStatus(connectorStatus); err != nil {
+		fmt.Println(err)
+	}
+
+	lifecycle := events.NewLifecycle(connectorStatus)
+	fmt.Println(lifecycle.Transition(connectorpb.State_STATE_PROVISIONING))
+	fmt.Println(lifecycle.Transition(connectorpb.State_STATE_PROVISIONED))
+	err := lifecycle.Transition(connectorpb.State_STATE_PROVISIONING)
+	fmt.Println(errors.Is(err, events.ErrIllegalTransition))
+	// Output:
+	// <nil>
+	// <nil>
+	// true
+}
+
+func ExampleEventWithEscalation() {
+	registry := events.NewRegistry(events.RegistryWithEscalationInterval(time.Minute))
+	defer registry.Close()
+
+	// raised by one severity level, up to CRITICAL, for every 15 minutes the alert stays active
+	slowResponses := events.NewAlert("slowResponses", "...", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY, events.EventWithEscalation(15*time.Minute))
+	if err := registry.RegisterAlert(slowResponses); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func ExampleRegistry_StartHeartbeat() {
+	registry := events.NewRegistry()
+	defer registry.Close()
+
+	heartbeat := events.NewStatus("heartbeat", "connector is alive", connectorpb.State_STATE_HEALTHY)
+	if err := registry.RegisterStatus(heartbeat); err != nil {
+		fmt.Println(err)
+	}
+	if err := registry.StartHeartbeat(heartbeat, 30*time.Second); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func ExampleRegistry_RetireStream() {
+	registry := events.NewRegistry()
+	unableToFetchData := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+	_ = unableToFetchData.Publish(events.AlertWithStreamID("s1"))
+	_ = unableToFetchData.Publish(events.AlertWithStreamID("s2"))
+
+	fmt.Println(registry.RetireStream("s1", events.RetireResolve))
+	// the remaining streams are reconciled with the streams of a SetPayload request
+	fmt.Println(registry.ReconcileStreams([]string{"s3"}, events.RetireDrop))
+	// Output:
+	// 1
+	// [s2]
+}
+
+func ExampleRegistryWithEventTTL() {
+	registry := events.NewRegistry(events.RegistryWithEventTTL(10 * time.Minute))
+	// closing the registry stops removing expired events in the background
+	defer registry.Close()
+	unableToContactDB := events.NewStatus("unableToContactDB", "...", connectorpb.State_STATE_UNHEALTHY)
+	if err := registry.RegisterStatus(unableToContactDB); err != nil {
+		fmt.Println(err)
+	}
+
+	// the ttl of the registry can be overridden per publish
+	_ = unableToContactDB.Publish(events.StatusWithTTL(time.Minute))
+}
+
+func ExampleRegistryWithDeliveryMode() {
+	registry := events.NewRegistry(events.RegistryWithDeliveryMode(events.DeliveryDelta))
+	unableToFetchData := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	configReloaded := events.NewAlert("configReloaded", "configuration reloaded", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY, events.EventWithDeliveryMode(events.DeliveryDrain))
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+	if err := registry.RegisterAlert(configReloaded); err != nil {
+		fmt.Println(err)
+	}
+
+	_ = unableToFetchData.Publish()
+	_ = configReloaded.Publish()
+	printEvents(registry)
+	fmt.Println("---")
+	printEvents(registry)
+	// Output:
+	// alert unableToFetchData SEVERITY_CRITICAL: ...
+	// alert configReloaded SEVERITY_INFO: configuration reloaded
+	// ---
+}
+
+func ExampleRegistryWithChildAlertMode() {
+	registry := events.NewRegistry(events.RegistryWithChildAlertMode(events.ChildAlertsSuppressed))
+	brokerUnreachable := events.NewAlert("brokerUnreachable", "broker is unreachable", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	unableToPublish := events.NewAlert("unableToPublish", "unable to publish", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+	if err := registry.RegisterAlert(brokerUnreachable); err != nil {
+		fmt.Println(err)
+	}
+	if err := registry.RegisterAlert(unableToPublish); err != nil {
+		fmt.Println(err)
+	}
+
+	_ = brokerUnreachable.Publish(events.AlertWithEventMetadata(&events.EventMetadata{CorrelationID: "outage-1"}))
+	_ = unableToPublish.Publish(events.AlertWithStreamID("s1"), events.AlertWithEventMetadata(&events.EventMetadata{CorrelationID: "outage-1", ParentAlert: "brokerUnreachable"}))
+	printEvents(registry)
+
+	alerts, _ := registry.Correlated("outage-1")
+	fmt.Println(len(alerts))
+	// Output:
+	// alert brokerUnreachable SEVERITY_CRITICAL: broker is unreachable
+	// 2
+}
+
+func ExampleRegistryWithMaxEvents() {
+	// stay below the maximum message size of the grpc server
+	registry := events.NewRegistry(events.RegistryWithMaxEvents(500), events.RegistryWithMaxEventBytes(2<<20))
+	_ = registry
+}
+
+func ExampleRegistry_AlertHistory() {
+	registry := events.NewRegistry(events.RegistryWithHistorySize(20))
+	unableToFetchData := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+
+	_ = unableToFetchData.Publish(events.AlertWithStreamID("s1"))
+	_ = unableToFetchData.Publish(events.AlertWithStreamID("s1"))
+	fmt.Println(len(registry.AlertHistory("unableToFetchData", "s1")))
+	// Output: 2
+}
+
+func ExampleRegistryWithSinks() {
+	webhook := events.NewWebhookSink("http://alertmanager:8080/events")
+	defer webhook.Close()
+
+	registry := events.NewRegistry(events.RegistryWithSinks(events.NewLogSink(), events.NewMetricsSink(prometheus.NewRegistry()), webhook))
+	_ = registry
+}
+
+func ExampleRegistry_Watch() {
+	registry := events.NewRegistry()
+	unableToFetchData := events.NewAlert("unableToFetchData", "...", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	if err := registry.RegisterAlert(unableToFetchData); err != nil {
+		fmt.Println(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := registry.Watch(ctx, events.WatchWithSeverities(connectorpb.Severity_SEVERITY_CRITICAL), events.WatchWithChangeTypes(events.EventPublished))
+
+	_ = unableToFetchData.Publish(events.AlertWithStreamID("s1"))
+	change := <-changes
+	fmt.Println(change.Type, change.Alert().GetId(), change.Alert().GetStreamId())
+	// Output: published unableToFetchData s1
+}
+
+func ExampleRegistryWithSnapshotStore() {
+	// active events are saved in the background after changes and restored when the registry is created
+	registry := events.NewRegistry(events.RegistryWithSnapshotStore(events.NewFileSnapshotStore("/var/lib/connector/events.json")))
+	// closing the registry saves the last changes
+	defer registry.Close()
+}
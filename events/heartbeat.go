@@ -0,0 +1,44 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const lastHeartbeatProp = "LastHeartbeat"
+
+// StartHeartbeat publishes the connector scoped status now and then at every interval until the registry is closed,
+// with the time of the latest heartbeat in the metadata as `LastHeartbeat`. The status has to be registered with
+// the registry. A heartbeat that stops being refreshed tells that the connector is stuck even though its last
+// published state looks fine.
+func (reg *Registry) StartHeartbeat(status Status, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("heartbeat interval must be positive")
+	}
+	if err := publishHeartbeat(status, reg.now()); err != nil {
+		return err
+	}
+
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+	if reg.closed {
+		return fmt.Errorf("registry is closed")
+	}
+	reg.runInBackground(interval, func() {
+		if err := publishHeartbeat(status, reg.now()); err != nil {
+			glog.Errorf("unable to publish heartbeat %s: %s", status.Name(), err.Error())
+		}
+	})
+	return nil
+}
+
+func publishHeartbeat(status Status, now time.Time) error {
+	return status.Publish(StatusWithEventMetadata(&EventMetadata{
+		Extra: map[string]interface{}{
+			lastHeartbeatProp: now,
+		},
+	}))
+}
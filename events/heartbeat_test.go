@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeartbeat(t *testing.T) {
+	t.Run("the heartbeat is refreshed until the registry is closed", func(t *testing.T) {
+		sink := &recordingSink{}
+		reg := NewRegistry(RegistryWithSinks(sink))
+		status := NewStatus("heartbeat", "connector is running", connectorpb.State_STATE_HEALTHY)
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, reg.StartHeartbeat(status, time.Millisecond))
+		statuses := reg.Statuses()
+		require.Len(t, statuses, 1)
+		extra := statuses[0].GetMetadata().GetFields()[extraProp].GetStructValue().GetFields()
+		assert.NotEmpty(t, extra[lastHeartbeatProp].GetStringValue())
+
+		assert.Eventually(t, func() bool {
+			return len(sink.types()) > 2
+		}, time.Second, time.Millisecond)
+
+		reg.Close()
+		published := len(sink.types())
+		time.Sleep(10 * time.Millisecond)
+		assert.Len(t, sink.types(), published)
+		assert.Error(t, reg.StartHeartbeat(status, time.Millisecond))
+	})
+
+	t.Run("an unregistered heartbeat status fails", func(t *testing.T) {
+		reg := NewRegistry()
+		defer reg.Close()
+		assert.Error(t, reg.StartHeartbeat(NewStatus("heartbeat", "connector is running", connectorpb.State_STATE_HEALTHY), time.Second))
+	})
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	// defaultHistorySize is the number of occurrences kept per event unless configured otherwise
+	defaultHistorySize = 10
+	// defaultHistoryRetention is how long the history of a removed event is kept unless configured otherwise
+	defaultHistoryRetention = time.Hour
+)
+
+// Occurrence describes a single publication of an event
+type Occurrence struct {
+	Time     time.Time
+	Message  string
+	Severity connectorpb.Severity
+	State    connectorpb.State
+	Metadata *structpb.Struct
+}
+
+// RegistryWithHistorySize sets the number of occurrences kept per event. A size of zero disables the history.
+func RegistryWithHistorySize(size int) RegistryOpts {
+	return func(reg *Registry) {
+		reg.historySize = size
+	}
+}
+
+// RegistryWithHistoryRetention sets how long the history of an event is kept after its latest occurrence once the
+// event has been removed, one hour by default. A non-positive retention keeps the histories of removed events until
+// their stream is retired.
+func RegistryWithHistoryRetention(retention time.Duration) RegistryOpts {
+	return func(reg *Registry) {
+		reg.historyRetention = retention
+	}
+}
+
+// historyRing is a fixed size ring buffer of occurrences
+type historyRing struct {
+	occurrences []Occurrence
+	next        int
+	full        bool
+}
+
+func newHistoryRing(size int) *historyRing {
+	return &historyRing{
+		occurrences: make([]Occurrence, size),
+	}
+}
+
+func (h *historyRing) add(o Occurrence) {
+	h.occurrences[h.next] = o
+	h.next = (h.next + 1) % len(h.occurrences)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns copies of the occurrences from the oldest to the newest
+func (h *historyRing) list() []Occurrence {
+	var list []Occurrence
+	if !h.full {
+		list = append(list, h.occurrences[:h.next]...)
+	} else {
+		list = append(append(list, h.occurrences[h.next:]...), h.occurrences[:h.next]...)
+	}
+	for i := range list {
+		list[i].Metadata = cloneMetadata(list[i].Metadata)
+	}
+	return list
+}
+
+// latest returns the time of the newest occurrence
+func (h *historyRing) latest() time.Time {
+	return h.occurrences[(h.next+len(h.occurrences)-1)%len(h.occurrences)].Time
+}
+
+func newOccurrence(event interface{}, t time.Time) Occurrence {
+	o := Occurrence{Time: t}
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		o.Message = event.Message
+		o.Severity = event.Severity
+		o.State = event.State
+		o.Metadata = cloneMetadata(event.Metadata)
+	case *connectorpb.Status:
+		o.Message = event.Message
+		o.State = event.State
+		o.Metadata = cloneMetadata(event.Metadata)
+	}
+	return o
+}
+
+// cloneMetadata returns a deep copy of the metadata, so that occurrences do not share it with published events
+func cloneMetadata(metadata *structpb.Struct) *structpb.Struct {
+	if metadata == nil {
+		return nil
+	}
+	return proto.Clone(metadata).(*structpb.Struct)
+}
+
+// AlertHistory returns the latest occurrences of the alert for the stream, from the oldest to the newest.
+// The history is kept after the alert has been resolved.
+func (reg *Registry) AlertHistory(name string, streamID string) []Occurrence {
+	return reg.history(alertKey(name, streamID))
+}
+
+// StatusHistory returns the latest occurrences of the status for the stream, from the oldest to the newest.
+// The history is kept after the status has been cleared.
+func (reg *Registry) StatusHistory(name string, streamID string) []Occurrence {
+	return reg.history(statusKey(name, streamID))
+}
+
+func (reg *Registry) history(key string) []Occurrence {
+	reg.rwLock.RLock()
+	defer reg.rwLock.RUnlock()
+
+	ring, ok := reg.histories[key]
+	if !ok {
+		return nil
+	}
+	return ring.list()
+}
+
+// recordOccurrence adds the event to the history. The caller must hold the write lock.
+func (reg *Registry) recordOccurrence(key string, event interface{}, t time.Time) {
+	if reg.historySize <= 0 {
+		return
+	}
+	ring, ok := reg.histories[key]
+	if !ok {
+		ring = newHistoryRing(reg.historySize)
+		reg.histories[key] = ring
+	}
+	ring.add(newOccurrence(event, t))
+}
+
+// pruneHistories removes the histories of removed events whose latest occurrence is older than the retention
+func (reg *Registry) pruneHistories() {
+	if reg.historyRetention <= 0 {
+		return
+	}
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+
+	now := reg.now()
+	for key, ring := range reg.histories {
+		if _, active := reg.events[key]; !active && now.Sub(ring.latest()) >= reg.historyRetention {
+			delete(reg.histories, key)
+		}
+	}
+}
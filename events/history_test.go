@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestEventHistory(t *testing.T) {
+	t.Run("repeated publications keep the creation time and count occurrences", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(100, 0)}
+		reg := NewRegistry()
+		reg.now = clock.now
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish())
+		clock.advance(time.Hour)
+		require.NoError(t, alert.Publish(AlertWithEventMetadata(&EventMetadata{ErrorMessage: "timeout"})))
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		a := payloads[0].GetAlert()
+		assert.Equal(t, int64(100), a.GetCreatedAt().GetSeconds())
+		fields := a.GetMetadata().GetFields()
+		assert.Equal(t, float64(2), fields[occurrencesProp].GetNumberValue())
+		assert.Equal(t, clock.t.UTC().Format(time.RFC3339Nano), fields[lastSeenAtProp].GetStringValue())
+		assert.Equal(t, "timeout", fields[errorMessageProp].GetStringValue())
+	})
+
+	t.Run("resolving an alert restarts its creation time", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(100, 0)}
+		reg := NewRegistry()
+		reg.now = clock.now
+		status := NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, status.Publish())
+		require.NoError(t, status.Clear())
+		clock.advance(time.Minute)
+		require.NoError(t, status.Publish())
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.Equal(t, int64(160), payloads[0].GetStatus().GetCreatedAt().GetSeconds())
+		assert.Equal(t, float64(1), payloads[0].GetStatus().GetMetadata().GetFields()[occurrencesProp].GetNumberValue())
+		assert.Len(t, reg.StatusHistory("unableToContactDB", ""), 2)
+	})
+
+	t.Run("history is bounded and ordered from oldest to newest", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry(RegistryWithHistorySize(3))
+		reg.now = clock.now
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		assert.Empty(t, reg.AlertHistory("unableToFetchData", "stream1"))
+		for i := 0; i < 5; i++ {
+			clock.advance(time.Second)
+			require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		}
+
+		history := reg.AlertHistory("unableToFetchData", "stream1")
+		require.Len(t, history, 3)
+		for i, o := range history {
+			assert.Equal(t, time.Unix(int64(i+3), 0), o.Time)
+			assert.Equal(t, connectorpb.Severity_SEVERITY_CRITICAL, o.Severity)
+			assert.Equal(t, "unable to fetch data", o.Message)
+		}
+		assert.Empty(t, reg.AlertHistory("unableToFetchData", ""))
+	})
+
+	t.Run("occurrences do not share metadata with published events", func(t *testing.T) {
+		sink := &recordingSink{}
+		reg := NewRegistry(RegistryWithSinks(sink))
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, alert.Publish(AlertWithEventMetadata(&EventMetadata{ErrorMessage: "timeout"})))
+
+		history := reg.AlertHistory("unableToFetchData", "")
+		require.Len(t, history, 1)
+		history[0].Metadata.Fields[errorMessageProp] = structpb.NewStringValue("changed")
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.Equal(t, "timeout", payloads[0].GetAlert().GetMetadata().GetFields()[errorMessageProp].GetStringValue())
+		assert.Equal(t, "timeout", reg.AlertHistory("unableToFetchData", "")[0].Metadata.GetFields()[errorMessageProp].GetStringValue())
+	})
+
+	t.Run("histories of removed events are pruned after the retention", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry(RegistryWithHistoryRetention(time.Minute))
+		reg.now = clock.now
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream2")))
+		require.NoError(t, alert.Resolve(AlertWithStreamID("stream1")))
+		clock.advance(time.Minute)
+		getEventPayloads(t, reg)
+
+		assert.Empty(t, reg.AlertHistory("unableToFetchData", "stream1"))
+		assert.Len(t, reg.AlertHistory("unableToFetchData", "stream2"), 1)
+	})
+}
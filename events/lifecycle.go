@@ -0,0 +1,224 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+)
+
+// ErrIllegalTransition is returned when a lifecycle is moved to a state that cannot follow its current state
+var ErrIllegalTransition = errors.New("illegal state transition")
+
+const (
+	previousStateProp  = "PreviousState"
+	transitionedAtProp = "TransitionedAt"
+)
+
+// TransitionPolicy decides how a lifecycle handles illegal transitions
+type TransitionPolicy int
+
+const (
+	// RejectIllegalTransitions fails illegal transitions with ErrIllegalTransition
+	RejectIllegalTransitions TransitionPolicy = iota
+	// LogIllegalTransitions logs illegal transitions and performs them anyway
+	LogIllegalTransitions
+)
+
+// DefaultTransitions returns the legal transitions between the states of a connector or a stream,
+// keyed by the state they start from
+func DefaultTransitions() map[connectorpb.State][]connectorpb.State {
+	return map[connectorpb.State][]connectorpb.State{
+		connectorpb.State_STATE_UNSPECIFIED: {
+			connectorpb.State_STATE_PROVISIONING,
+		},
+		connectorpb.State_STATE_PROVISIONING: {
+			connectorpb.State_STATE_PROVISIONED, connectorpb.State_STATE_FAILED,
+		},
+		connectorpb.State_STATE_PROVISIONED: {
+			connectorpb.State_STATE_HEALTHY, connectorpb.State_STATE_UNHEALTHY, connectorpb.State_STATE_FAILED,
+		},
+		connectorpb.State_STATE_HEALTHY: {
+			connectorpb.State_STATE_UNHEALTHY, connectorpb.State_STATE_FAILED, connectorpb.State_STATE_PROVISIONING,
+		},
+		connectorpb.State_STATE_UNHEALTHY: {
+			connectorpb.State_STATE_HEALTHY, connectorpb.State_STATE_FAILED, connectorpb.State_STATE_PROVISIONING,
+		},
+		connectorpb.State_STATE_FAILED: {
+			connectorpb.State_STATE_PROVISIONING,
+		},
+	}
+}
+
+// Transition is a change of the state of a connector or a stream
+type Transition struct {
+	From connectorpb.State
+	To   connectorpb.State
+	Time time.Time
+}
+
+// LifecycleOpts defines the type for the functional options for creating a lifecycle
+type LifecycleOpts func(*Lifecycle)
+
+// LifecycleWithPolicy sets how illegal transitions are handled. Illegal transitions are rejected by default.
+func LifecycleWithPolicy(policy TransitionPolicy) LifecycleOpts {
+	return func(l *Lifecycle) {
+		l.policy = policy
+	}
+}
+
+// LifecycleWithTransitions replaces the legal transitions, keyed by the state they start from
+func LifecycleWithTransitions(transitions map[connectorpb.State][]connectorpb.State) LifecycleOpts {
+	return func(l *Lifecycle) {
+		l.transitions = transitions
+	}
+}
+
+// Lifecycle tracks the state of the connector and of each of its streams. Every transition is validated,
+// recorded and published through a status, so that the status always follows the lifecycle.
+type Lifecycle struct {
+	status      Status
+	policy      TransitionPolicy
+	transitions map[connectorpb.State][]connectorpb.State
+	scopes      map[string]*lifecycleScope
+	now         func() time.Time
+	lock        sync.Mutex
+}
+
+// lifecycleScope is the state of the connector or of a stream
+type lifecycleScope struct {
+	state   connectorpb.State
+	history []Transition
+}
+
+// NewLifecycle creates a lifecycle that publishes its states through the status. The status has to be
+// registered with a registry before the first transition.
+func NewLifecycle(status Status, opts ...LifecycleOpts) *Lifecycle {
+	l := &Lifecycle{
+		status:      status,
+		policy:      RejectIllegalTransitions,
+		transitions: DefaultTransitions(),
+		scopes:      make(map[string]*lifecycleScope),
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Transition moves the connector to the state and publishes the status with the options
+func (l *Lifecycle) Transition(to connectorpb.State, opts ...StatusOpts) error {
+	return l.TransitionStream("", to, opts...)
+}
+
+// TransitionStream moves the stream to the state and publishes the status for the stream with the options.
+// Moving to the current state publishes the status again without recording a transition.
+func (l *Lifecycle) TransitionStream(streamID string, to connectorpb.State, opts ...StatusOpts) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	scope, ok := l.scopes[streamID]
+	if !ok {
+		scope = &lifecycleScope{}
+	}
+	from := scope.state
+
+	if from != to && !l.legal(from, to) {
+		err := fmt.Errorf("unable to move %s from %s to %s: %w", scopeName(streamID), from, to, ErrIllegalTransition)
+		if l.policy == RejectIllegalTransitions {
+			return err
+		}
+		glog.Warning(err.Error())
+	}
+
+	now := l.now()
+	metadata := &EventMetadata{
+		StreamID: streamID,
+		Extra: map[string]interface{}{
+			previousStateProp:  from.String(),
+			transitionedAtProp: now,
+		},
+	}
+	if from == to && len(scope.history) > 0 {
+		last := scope.history[len(scope.history)-1]
+		metadata.Extra[previousStateProp] = last.From.String()
+		metadata.Extra[transitionedAtProp] = last.Time
+	}
+	publishOpts := append([]StatusOpts{
+		StatusWithStreamID(streamID),
+		StatusWithState(to),
+		StatusWithEventMetadata(metadata),
+	}, opts...)
+	if err := l.status.Publish(publishOpts...); err != nil {
+		return err
+	}
+
+	l.scopes[streamID] = scope
+	if from == to {
+		return nil
+	}
+	scope.state = to
+	scope.history = append(scope.history, Transition{From: from, To: to, Time: now})
+	if len(scope.history) > defaultHistorySize {
+		scope.history = scope.history[len(scope.history)-defaultHistorySize:]
+	}
+	return nil
+}
+
+// State returns the current state of the connector
+func (l *Lifecycle) State() connectorpb.State {
+	return l.StreamState("")
+}
+
+// StreamState returns the current state of the stream, STATE_UNSPECIFIED if it has not been moved yet
+func (l *Lifecycle) StreamState(streamID string) connectorpb.State {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if scope, ok := l.scopes[streamID]; ok {
+		return scope.state
+	}
+	return connectorpb.State_STATE_UNSPECIFIED
+}
+
+// Transitions returns the latest transitions of the stream, or of the connector for an empty stream ID, oldest first
+func (l *Lifecycle) Transitions(streamID string) []Transition {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	scope, ok := l.scopes[streamID]
+	if !ok {
+		return nil
+	}
+	return append([]Transition(nil), scope.history...)
+}
+
+// RemoveStream stops tracking the stream and clears its status
+func (l *Lifecycle) RemoveStream(streamID string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.scopes, streamID)
+	return l.status.Clear(StatusWithStreamID(streamID))
+}
+
+func (l *Lifecycle) legal(from connectorpb.State, to connectorpb.State) bool {
+	for _, state := range l.transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+func scopeName(streamID string) string {
+	if streamID == "" {
+		return "connector"
+	}
+	return "stream " + streamID
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycle(t *testing.T) {
+	newLifecycle := func(t *testing.T, opts ...LifecycleOpts) (*Registry, *Lifecycle, *fakeClock) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry()
+		reg.now = clock.now
+		status := NewStatus("lifecycle", "lifecycle", connectorpb.State_STATE_UNSPECIFIED)
+		require.NoError(t, reg.RegisterStatus(status))
+		lifecycle := NewLifecycle(status, opts...)
+		lifecycle.now = clock.now
+		return reg, lifecycle, clock
+	}
+
+	t.Run("legal transitions are recorded and published", func(t *testing.T) {
+		reg, lifecycle, clock := newLifecycle(t)
+
+		require.NoError(t, lifecycle.Transition(connectorpb.State_STATE_PROVISIONING))
+		clock.advance(time.Second)
+		require.NoError(t, lifecycle.Transition(connectorpb.State_STATE_PROVISIONED))
+		require.NoError(t, lifecycle.TransitionStream("stream1", connectorpb.State_STATE_PROVISIONING))
+
+		assert.Equal(t, connectorpb.State_STATE_PROVISIONED, lifecycle.State())
+		assert.Equal(t, connectorpb.State_STATE_PROVISIONING, lifecycle.StreamState("stream1"))
+		assert.Equal(t, []Transition{
+			{From: connectorpb.State_STATE_UNSPECIFIED, To: connectorpb.State_STATE_PROVISIONING, Time: time.Unix(0, 0)},
+			{From: connectorpb.State_STATE_PROVISIONING, To: connectorpb.State_STATE_PROVISIONED, Time: time.Unix(1, 0)},
+		}, lifecycle.Transitions(""))
+
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 2)
+		for _, payload := range payloads {
+			status := payload.GetStatus()
+			assert.Equal(t, lifecycle.StreamState(status.GetStreamId()), status.GetState())
+			if status.GetStreamId() == "" {
+				extra := status.GetMetadata().GetFields()[extraProp].GetStructValue().GetFields()
+				assert.Equal(t, connectorpb.State_STATE_PROVISIONING.String(), extra[previousStateProp].GetStringValue())
+			}
+		}
+	})
+
+	t.Run("illegal transitions are rejected", func(t *testing.T) {
+		reg, lifecycle, _ := newLifecycle(t)
+
+		assert.ErrorIs(t, lifecycle.Transition(connectorpb.State_STATE_HEALTHY), ErrIllegalTransition)
+		assert.Equal(t, connectorpb.State_STATE_UNSPECIFIED, lifecycle.State())
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+
+	t.Run("illegal transitions are performed when only logged", func(t *testing.T) {
+		_, lifecycle, _ := newLifecycle(t, LifecycleWithPolicy(LogIllegalTransitions))
+
+		require.NoError(t, lifecycle.Transition(connectorpb.State_STATE_HEALTHY))
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, lifecycle.State())
+		assert.Len(t, lifecycle.Transitions(""), 1)
+	})
+
+	t.Run("republishing the current state records no transition", func(t *testing.T) {
+		_, lifecycle, _ := newLifecycle(t)
+
+		require.NoError(t, lifecycle.Transition(connectorpb.State_STATE_PROVISIONING))
+		require.NoError(t, lifecycle.Transition(connectorpb.State_STATE_PROVISIONING))
+		assert.Len(t, lifecycle.Transitions(""), 1)
+	})
+
+	t.Run("removing a stream clears its status", func(t *testing.T) {
+		reg, lifecycle, _ := newLifecycle(t)
+
+		require.NoError(t, lifecycle.TransitionStream("stream1", connectorpb.State_STATE_PROVISIONING))
+		require.NoError(t, lifecycle.RemoveStream("stream1"))
+		assert.Equal(t, connectorpb.State_STATE_UNSPECIFIED, lifecycle.StreamState("stream1"))
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+}
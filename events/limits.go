@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// TruncatedStatusID is the ID of the status added to GetEvents responses that leave events out
+	TruncatedStatusID = "eventsTruncated"
+
+	truncatedEventsProp = "TruncatedEvents"
+	totalEventsProp     = "TotalEvents"
+
+	// payloadOverhead is an upper bound of the bytes needed for framing an event payload in a response
+	payloadOverhead = 8
+)
+
+// RegistryWithMaxEvents caps the number of events returned by GetEvents. The truncation status is added on top of
+// the cap, so that it never takes the place of an event.
+func RegistryWithMaxEvents(max int) RegistryOpts {
+	return func(reg *Registry) {
+		reg.maxEvents = max
+	}
+}
+
+// RegistryWithMaxEventBytes caps the encoded size of the events returned by GetEvents, including the truncation
+// status, e.g. to stay below the maximum message size of the grpc server. The truncation status is left out rather
+// than the event with the highest priority if they do not fit together.
+func RegistryWithMaxEventBytes(max int) RegistryOpts {
+	return func(reg *Registry) {
+		reg.maxEventBytes = max
+	}
+}
+
+// dueEvent is an event due on a scrape
+type dueEvent struct {
+	key   string
+	entry *eventEntry
+	// event is the event returned for the entry
+	event interface{}
+	// tombstone is set for the last delivery of a removed delta event
+	tombstone bool
+}
+
+// eventPriority ranks alerts by severity and statuses by the severity matching their state
+func eventPriority(event interface{}) int {
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		return int(event.Severity)
+	case *connectorpb.Status:
+		switch event.State {
+		case connectorpb.State_STATE_FAILED:
+			return int(connectorpb.Severity_SEVERITY_CRITICAL)
+		case connectorpb.State_STATE_UNHEALTHY:
+			return int(connectorpb.Severity_SEVERITY_WARNING)
+		}
+		return int(connectorpb.Severity_SEVERITY_INFO)
+	}
+	return 0
+}
+
+// sortDueEvents orders the events by priority, then from the most to the least recently published, then by key
+func sortDueEvents(events []dueEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if pa, pb := eventPriority(a.event), eventPriority(b.event); pa != pb {
+			return pa > pb
+		}
+		if !a.entry.lastSeen.Equal(b.entry.lastSeen) {
+			return a.entry.lastSeen.After(b.entry.lastSeen)
+		}
+		return a.key < b.key
+	})
+}
+
+// limitEvents returns the number of leading events that fit the caps of the registry along with
+// the truncation status. The caller must hold the lock.
+func (reg *Registry) limitEvents(events []dueEvent, now time.Time) (int, *connectorpb.Status) {
+	if reg.maxEvents <= 0 && reg.maxEventBytes <= 0 {
+		return len(events), nil
+	}
+
+	sizes := make([]int, len(events))
+	total := 0
+	for i, e := range events {
+		sizes[i] = eventSize(e.event)
+		total += sizes[i]
+	}
+	if reg.fits(len(events), total) {
+		return len(events), nil
+	}
+
+	included, size := 0, 0
+	for included < len(events) && reg.fits(included+1, size+sizes[included]) {
+		size += sizes[included]
+		included++
+	}
+	status := truncatedStatus(len(events)-included, len(events), now)
+	for included > 1 && !reg.fitsBytes(size+eventSize(status)) {
+		included--
+		size -= sizes[included]
+		status = truncatedStatus(len(events)-included, len(events), now)
+	}
+	if !reg.fitsBytes(size + eventSize(status)) {
+		return included, nil
+	}
+	return included, status
+}
+
+// fits reports whether the number and the size of events fit the caps, leaving out the truncation status
+func (reg *Registry) fits(count int, size int) bool {
+	if reg.maxEvents > 0 && count > reg.maxEvents {
+		return false
+	}
+	return reg.fitsBytes(size)
+}
+
+func (reg *Registry) fitsBytes(size int) bool {
+	return reg.maxEventBytes <= 0 || size <= reg.maxEventBytes
+}
+
+func eventSize(event interface{}) int {
+	return proto.Size(toEventPayload(event)) + payloadOverhead
+}
+
+// truncatedStatus creates the status reporting the number of events left out of a response. It is healthy, as
+// leaving events out does not affect the health of the connector.
+func truncatedStatus(truncated int, total int, now time.Time) *connectorpb.Status {
+	metadata := &EventMetadata{
+		Extra: map[string]interface{}{
+			truncatedEventsProp: truncated,
+			totalEventsProp:     total,
+		},
+	}
+	return &connectorpb.Status{
+		Id:        TruncatedStatusID,
+		Message:   fmt.Sprintf("%d of %d events were left out of the response", truncated, total),
+		State:     connectorpb.State_STATE_HEALTHY,
+		CreatedAt: timestamppb.New(now),
+		Metadata:  metadata.toStruct(),
+	}
+}
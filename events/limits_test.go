@@ -0,0 +1,96 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventLimits(t *testing.T) {
+	ids := func(payloads []*connectorpb.EventPayload) []string {
+		var ids []string
+		for _, p := range payloads {
+			if alert := p.GetAlert(); alert != nil {
+				ids = append(ids, alert.GetId()+"/"+alert.GetStreamId())
+			} else {
+				ids = append(ids, p.GetStatus().GetId())
+			}
+		}
+		return ids
+	}
+
+	newRegistry := func(t *testing.T, opts ...RegistryOpts) *Registry {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry(opts...)
+		reg.now = clock.now
+
+		info := NewAlert("configReloaded", "configuration reloaded", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY)
+		critical := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		status := NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterAlert(info))
+		require.NoError(t, reg.RegisterAlert(critical))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, info.Publish())
+		clock.advance(time.Second)
+		require.NoError(t, critical.Publish(AlertWithStreamID("s1")))
+		clock.advance(time.Second)
+		require.NoError(t, critical.Publish(AlertWithStreamID("s2")))
+		clock.advance(time.Second)
+		require.NoError(t, status.Publish())
+		return reg
+	}
+
+	t.Run("events are ordered by severity and recency", func(t *testing.T) {
+		reg := newRegistry(t)
+		expected := []string{"unableToFetchData/s2", "unableToFetchData/s1", "unableToContactDB", "configReloaded/"}
+		assert.Equal(t, expected, ids(getEventPayloads(t, reg)))
+		assert.Equal(t, expected, ids(getEventPayloads(t, reg)))
+	})
+
+	t.Run("the event count is capped with a truncation status", func(t *testing.T) {
+		reg := newRegistry(t, RegistryWithMaxEvents(2))
+		payloads := getEventPayloads(t, reg)
+		assert.Equal(t, []string{"unableToFetchData/s2", "unableToFetchData/s1", TruncatedStatusID}, ids(payloads))
+
+		truncated := payloads[2].GetStatus()
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, truncated.GetState())
+		extra := truncated.GetMetadata().GetFields()[extraProp].GetStructValue().GetFields()
+		assert.Equal(t, float64(2), extra[truncatedEventsProp].GetNumberValue())
+		assert.Equal(t, float64(4), extra[totalEventsProp].GetNumberValue())
+		assert.Len(t, reg.Statuses(), 1)
+	})
+
+	t.Run("the truncation status does not push out the highest priority event", func(t *testing.T) {
+		reg := newRegistry(t, RegistryWithMaxEvents(1))
+		assert.Equal(t, []string{"unableToFetchData/s2", TruncatedStatusID}, ids(getEventPayloads(t, reg)))
+
+		reg = newRegistry(t)
+		reg.maxEventBytes = eventSize(getEventPayloads(t, reg)[0].GetAlert())
+		assert.Equal(t, []string{"unableToFetchData/s2"}, ids(getEventPayloads(t, reg)))
+	})
+
+	t.Run("events fitting the caps are not truncated", func(t *testing.T) {
+		reg := newRegistry(t, RegistryWithMaxEvents(4), RegistryWithMaxEventBytes(1<<20))
+		assert.Len(t, getEventPayloads(t, reg), 4)
+	})
+
+	t.Run("the encoded size is capped", func(t *testing.T) {
+		reg := newRegistry(t)
+		all := getEventPayloads(t, reg)
+		reg.maxEventBytes = eventSize(all[0].GetAlert()) + eventSize(truncatedStatus(3, 4, time.Unix(3, 0)))
+
+		assert.Equal(t, []string{"unableToFetchData/s2", TruncatedStatusID}, ids(getEventPayloads(t, reg)))
+	})
+
+	t.Run("truncated events are delivered on a later scrape", func(t *testing.T) {
+		reg := newRegistry(t, RegistryWithMaxEvents(2), RegistryWithDeliveryMode(DeliveryDrain))
+		assert.Equal(t, []string{"unableToFetchData/s2", "unableToFetchData/s1", TruncatedStatusID}, ids(getEventPayloads(t, reg)))
+		assert.Equal(t, []string{"unableToContactDB", "configReloaded/"}, ids(getEventPayloads(t, reg)))
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+}
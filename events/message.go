@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/golang/glog"
+)
+
+// messageTemplate is the message of an event. The message is used as-is unless values are
+// supplied at publish time, in which case it is rendered either as a text/template or as a
+// printf-style format string.
+type messageTemplate struct {
+	text string
+	tmpl *template.Template
+}
+
+func newMessageTemplate(name string, text string) messageTemplate {
+	m := messageTemplate{text: text}
+	if !strings.Contains(text, "{{") {
+		return m
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		glog.Errorf("unable to parse message template of %s: %s", name, err.Error())
+		return m
+	}
+	m.tmpl = tmpl
+	return m
+}
+
+// render renders the message with the template data if present, otherwise with the printf arguments.
+// If rendering fails, the unrendered message is returned.
+func (m messageTemplate) render(args []interface{}, data interface{}) string {
+	if data != nil {
+		if m.tmpl == nil {
+			return m.text
+		}
+		var buf bytes.Buffer
+		if err := m.tmpl.Execute(&buf, data); err != nil {
+			glog.Errorf("unable to render message template %q: %s", m.text, err.Error())
+			return m.text
+		}
+		return buf.String()
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(m.text, args...)
+	}
+	return m.text
+}
+
+// String returns the unrendered message
+func (m messageTemplate) String() string {
+	return m.text
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageTemplate(t *testing.T) {
+	t.Run("messages are rendered at publish time", func(t *testing.T) {
+		reg := NewRegistry()
+		alert := NewAlert("unableToConnect", "unable to connect to {{.Host}}:{{.Port}}", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		status := NewStatus("lagging", "stream is %d messages behind", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		data := map[string]interface{}{"Host": "db", "Port": 5432}
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1"), AlertWithMessageData(data)))
+		require.NoError(t, status.Publish(StatusWithStreamID("stream1"), StatusWithMessageArgs(42)))
+
+		for _, payload := range getEventPayloads(t, reg) {
+			if a := payload.GetAlert(); a != nil {
+				assert.Equal(t, "unableToConnect", a.GetId())
+				assert.Equal(t, "unable to connect to db:5432", a.GetMessage())
+				assert.Equal(t, "unable to connect to {{.Host}}:{{.Port}}", a.GetMetadata().GetFields()[messageTemplateProp].GetStringValue())
+			}
+			if s := payload.GetStatus(); s != nil {
+				assert.Equal(t, "stream is 42 messages behind", s.GetMessage())
+				assert.Equal(t, "stream is %d messages behind", s.GetMetadata().GetFields()[messageTemplateProp].GetStringValue())
+			}
+		}
+	})
+
+	t.Run("rendered messages keep the event identity", func(t *testing.T) {
+		reg := NewRegistry()
+		status := NewStatus("lagging", "stream is %d messages behind", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, status.Publish(StatusWithMessageArgs(1)))
+		require.NoError(t, status.Publish(StatusWithMessageArgs(2)))
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.Equal(t, "stream is 2 messages behind", payloads[0].GetStatus().GetMessage())
+	})
+
+	t.Run("messages fall back to the unrendered text", func(t *testing.T) {
+		m := newMessageTemplate("missing", "unable to connect to {{.Host}}")
+		assert.Equal(t, "unable to connect to {{.Host}}", m.render(nil, map[string]interface{}{}))
+		assert.Equal(t, "unable to connect to {{.Host}}", m.render(nil, nil))
+
+		m = newMessageTemplate("invalid", "unable to connect to {{.Host")
+		assert.Equal(t, "unable to connect to {{.Host", m.render(nil, map[string]interface{}{"Host": "db"}))
+	})
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// EventMetadata is a mechanism for adding extra arbitrary properties to an event as metadata
+type EventMetadata struct {
+	ErrorMessage string
+	StreamID     string
+	// ErrorType is the Go type of the error the metadata was created from
+	ErrorType string
+	// ErrorCauses are the errors wrapped by the error the metadata was created from, outermost first
+	ErrorCauses []ErrorCause
+	// CorrelationID groups the events caused by the same condition, e.g. a broker outage
+	CorrelationID string
+	// ParentAlert is the name of the alert causing the event, published for the same stream or the whole connector
+	ParentAlert string
+	// Extra holds arbitrary values. Values that have no direct protobuf representation, e.g. time.Time,
+	// structs or typed slices, are converted through their JSON encoding, or else their string representation.
+	Extra map[string]interface{}
+}
+
+// ErrorCause is an error in the chain of wrapped errors
+type ErrorCause struct {
+	Type    string
+	Message string
+}
+
+const (
+	errorMessageProp  = "ErrorMessage"
+	streamIDProp      = "StreamID"
+	errorTypeProp     = "ErrorType"
+	errorCausesProp   = "ErrorCauses"
+	extraProp         = "Extra"
+	correlationIDProp = "CorrelationID"
+	parentAlertProp   = "ParentAlert"
+)
+
+// EventMetadataFromError creates metadata describing the error, its type and the chain of errors it wraps.
+// The AlertErrors binding alerts to errors are left out of the type and the chain.
+func EventMetadataFromError(err error) *EventMetadata {
+	if err == nil {
+		return &EventMetadata{}
+	}
+	em := &EventMetadata{
+		ErrorMessage: err.Error(),
+	}
+	for cause := err; cause != nil; cause = errors.Unwrap(cause) {
+		if _, ok := cause.(*AlertError); ok {
+			continue
+		}
+		if em.ErrorType == "" {
+			em.ErrorType = fmt.Sprintf("%T", cause)
+			continue
+		}
+		em.ErrorCauses = append(em.ErrorCauses, ErrorCause{
+			Type:    fmt.Sprintf("%T", cause),
+			Message: cause.Error(),
+		})
+	}
+	return em
+}
+
+// toStruct converts the metadata to its protobuf representation. Values that cannot be converted are
+// replaced by their string representation rather than failing the publication.
+func (em *EventMetadata) toStruct() *structpb.Struct {
+	fields := map[string]*structpb.Value{
+		errorMessageProp: structpb.NewStringValue(em.ErrorMessage),
+		streamIDProp:     structpb.NewStringValue(em.StreamID),
+	}
+	if em.ErrorType != "" {
+		fields[errorTypeProp] = structpb.NewStringValue(em.ErrorType)
+	}
+	if len(em.ErrorCauses) > 0 {
+		causes := make([]interface{}, 0, len(em.ErrorCauses))
+		for _, cause := range em.ErrorCauses {
+			causes = append(causes, map[string]interface{}{
+				"Type":    cause.Type,
+				"Message": cause.Message,
+			})
+		}
+		fields[errorCausesProp] = toValue(errorCausesProp, causes)
+	}
+	if em.CorrelationID != "" {
+		fields[correlationIDProp] = structpb.NewStringValue(em.CorrelationID)
+	}
+	if em.ParentAlert != "" {
+		fields[parentAlertProp] = structpb.NewStringValue(em.ParentAlert)
+	}
+	if em.Extra != nil {
+		extra := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(em.Extra))}
+		for key, value := range em.Extra {
+			extra.Fields[key] = toValue(key, value)
+		}
+		fields[extraProp] = structpb.NewStructValue(extra)
+	}
+	return &structpb.Struct{Fields: fields}
+}
+
+// toValue converts an arbitrary value to a protobuf value, trying a direct conversion first,
+// then a conversion of its JSON encoding and finally its string representation
+func toValue(key string, v interface{}) *structpb.Value {
+	switch v := v.(type) {
+	case error:
+		return structpb.NewStringValue(v.Error())
+	case time.Time:
+		return structpb.NewStringValue(v.UTC().Format(time.RFC3339Nano))
+	}
+
+	if value, err := structpb.NewValue(v); err == nil {
+		return value
+	}
+	if data, err := json.Marshal(v); err == nil {
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err == nil {
+			if value, err := structpb.NewValue(decoded); err == nil {
+				return value
+			}
+		}
+	}
+	glog.Warningf("unable to convert metadata property %s of type %T, using its string representation", key, v)
+	return structpb.NewStringValue(fmt.Sprintf("%v", v))
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutError struct {
+	op string
+}
+
+func (e *timeoutError) Error() string { return e.op + ": timeout" }
+
+func TestEventMetadata(t *testing.T) {
+	t.Run("extra values without a protobuf representation are converted", func(t *testing.T) {
+		type endpoint struct {
+			Host string `json:"host"`
+			Port int    `json:"port"`
+		}
+		at := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+		metadata := (&EventMetadata{
+			Extra: map[string]interface{}{
+				"at":        at,
+				"endpoint":  endpoint{Host: "db", Port: 5432},
+				"ports":     []int{80, 443},
+				"cause":     errors.New("refused"),
+				"unencoded": math.NaN(),
+				"channel":   make(chan int),
+			},
+		}).toStruct()
+
+		extra := metadata.GetFields()[extraProp].GetStructValue().GetFields()
+		assert.Equal(t, "2021-03-01T12:00:00Z", extra["at"].GetStringValue())
+		assert.Equal(t, "db", extra["endpoint"].GetStructValue().GetFields()["host"].GetStringValue())
+		assert.Equal(t, float64(5432), extra["endpoint"].GetStructValue().GetFields()["port"].GetNumberValue())
+		assert.Len(t, extra["ports"].GetListValue().GetValues(), 2)
+		assert.Equal(t, "refused", extra["cause"].GetStringValue())
+		assert.True(t, math.IsNaN(extra["unencoded"].GetNumberValue()))
+		assert.NotEmpty(t, extra["channel"].GetStringValue())
+		assert.NotContains(t, metadata.GetFields(), "ExtraMessage")
+	})
+
+	t.Run("metadata from an error records its type and cause chain", func(t *testing.T) {
+		cause := &timeoutError{op: "dial"}
+		err := fmt.Errorf("unable to load config: %w", fmt.Errorf("unable to reach server: %w", cause))
+
+		metadata := EventMetadataFromError(err)
+		assert.Equal(t, err.Error(), metadata.ErrorMessage)
+		assert.Equal(t, "*fmt.wrapError", metadata.ErrorType)
+		require.Len(t, metadata.ErrorCauses, 2)
+		assert.Equal(t, "*fmt.wrapError", metadata.ErrorCauses[0].Type)
+		assert.Equal(t, "*events.timeoutError", metadata.ErrorCauses[1].Type)
+		assert.Equal(t, cause.Error(), metadata.ErrorCauses[1].Message)
+
+		fields := metadata.toStruct().GetFields()
+		assert.Equal(t, "*fmt.wrapError", fields[errorTypeProp].GetStringValue())
+		causes := fields[errorCausesProp].GetListValue().GetValues()
+		require.Len(t, causes, 2)
+		assert.Equal(t, "*events.timeoutError", causes[1].GetStructValue().GetFields()["Type"].GetStringValue())
+	})
+
+	t.Run("unconvertible metadata does not prevent publishing", func(t *testing.T) {
+		reg := NewRegistry()
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish(AlertWithEventMetadata(&EventMetadata{
+			Extra: map[string]interface{}{"callback": func() {}},
+		})))
+		assert.Len(t, getEventPayloads(t, reg), 1)
+	})
+}
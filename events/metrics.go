@@ -0,0 +1,124 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"github.com/golang/glog"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	statusStateDesc = prometheus.NewDesc(
+		"events_status_state",
+		"Current state of a status as the value of the connector.v1.State enum",
+		[]string{"id", "stream_id"}, nil,
+	)
+	activeAlertsDesc = prometheus.NewDesc(
+		"events_active_alerts",
+		"Number of alerts currently held by the registry",
+		[]string{"severity"}, nil,
+	)
+)
+
+// registryMetrics are the prometheus collectors maintained by a registry
+type registryMetrics struct {
+	suppressedFlaps     *prometheus.CounterVec
+	suppressedPublishes *prometheus.CounterVec
+	alertPublications   *prometheus.CounterVec
+}
+
+func newRegistryMetrics() *registryMetrics {
+	return &registryMetrics{
+		suppressedFlaps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_suppressed_flaps",
+			Help: "Number of event state changes that reverted before they were exposed",
+		}, []string{"type", "id"}),
+		suppressedPublishes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_suppressed_publishes",
+			Help: "Number of event publications that were not exposed",
+		}, []string{"type", "id", "reason"}),
+		alertPublications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_alert_publications",
+			Help: "Number of alert publications exposed by the registry",
+		}, []string{"id", "severity", "stream_id"}),
+	}
+}
+
+// RegistryWithMetrics registers the metrics of the registry with the prometheus registerer. Besides counters
+// of alert publications and suppressed events, these include gauges of the current state of every status and
+// of the number of active alerts. Registries sharing a registerer share their counters, whereas the gauges
+// are only exported for the first registry.
+func RegistryWithMetrics(registerer prometheus.Registerer) RegistryOpts {
+	return func(reg *Registry) {
+		reg.metrics.suppressedFlaps = registerCounterVec(registerer, reg.metrics.suppressedFlaps)
+		reg.metrics.suppressedPublishes = registerCounterVec(registerer, reg.metrics.suppressedPublishes)
+		reg.metrics.alertPublications = registerCounterVec(registerer, reg.metrics.alertPublications)
+
+		err := registerer.Register(&registryCollector{registry: reg})
+		if _, ok := err.(prometheus.AlreadyRegisteredError); err != nil && !ok {
+			glog.Errorf("unable to register events metrics: %s", err.Error())
+		}
+	}
+}
+
+// observe updates the metrics for a change of an event. The caller must hold the write lock.
+func (m *registryMetrics) observe(change EventChange) {
+	alert := change.Alert()
+	if alert == nil || change.Type == EventResolved {
+		return
+	}
+	m.alertPublications.WithLabelValues(alert.Id, alert.Severity.String(), alert.StreamId).Inc()
+}
+
+// registryCollector exports gauges derived from the events currently held by a registry
+type registryCollector struct {
+	registry *Registry
+}
+
+// Describe sends the descriptors of the gauges
+func (c *registryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- statusStateDesc
+	ch <- activeAlertsDesc
+}
+
+// Collect sends the current values of the gauges
+func (c *registryCollector) Collect(ch chan<- prometheus.Metric) {
+	reg := c.registry
+	reg.rwLock.RLock()
+	defer reg.rwLock.RUnlock()
+
+	now := reg.now()
+	activeAlerts := make(map[connectorpb.Severity]int)
+	for severity := range connectorpb.Severity_name {
+		activeAlerts[connectorpb.Severity(severity)] = 0
+	}
+	for _, entry := range reg.events {
+		if entry.expired(now) {
+			continue
+		}
+		switch event := entry.event.(type) {
+		case *connectorpb.Alert:
+			activeAlerts[event.Severity]++
+		case *connectorpb.Status:
+			ch <- prometheus.MustNewConstMetric(statusStateDesc, prometheus.GaugeValue, float64(event.State), event.Id, event.StreamId)
+		}
+	}
+	for severity, count := range activeAlerts {
+		ch <- prometheus.MustNewConstMetric(activeAlertsDesc, prometheus.GaugeValue, float64(count), severity.String())
+	}
+}
+
+// registerCounterVec registers the counter and returns it, or the equivalent counter that was already registered
+func registerCounterVec(registerer prometheus.Registerer, counter *prometheus.CounterVec) *prometheus.CounterVec {
+	err := registerer.Register(counter)
+	if err == nil {
+		return counter
+	}
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		if existing, ok := are.ExistingCollector.(*prometheus.CounterVec); ok {
+			return existing
+		}
+	}
+	glog.Errorf("unable to register events metrics: %s", err.Error())
+	return counter
+}
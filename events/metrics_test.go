@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"strings"
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryMetrics(t *testing.T) {
+	t.Run("registry exports status states, active alerts and alert publications", func(t *testing.T) {
+		promRegistry := prometheus.NewRegistry()
+		reg := NewRegistry(RegistryWithMetrics(promRegistry))
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		status := NewStatus("dbHealth", "database health", connectorpb.State_STATE_HEALTHY)
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream2")))
+		require.NoError(t, status.Publish())
+		require.NoError(t, status.Publish(StatusWithStreamID("stream1"), StatusWithState(connectorpb.State_STATE_UNHEALTHY)))
+		require.NoError(t, alert.Resolve(AlertWithStreamID("stream2")))
+
+		expected := `
+# HELP events_active_alerts Number of alerts currently held by the registry
+# TYPE events_active_alerts gauge
+events_active_alerts{severity="SEVERITY_CRITICAL"} 1
+events_active_alerts{severity="SEVERITY_INFO"} 0
+events_active_alerts{severity="SEVERITY_UNSPECIFIED"} 0
+events_active_alerts{severity="SEVERITY_WARNING"} 0
+# HELP events_alert_publications Number of alert publications exposed by the registry
+# TYPE events_alert_publications counter
+events_alert_publications{id="unableToFetchData",severity="SEVERITY_CRITICAL",stream_id="stream1"} 2
+events_alert_publications{id="unableToFetchData",severity="SEVERITY_CRITICAL",stream_id="stream2"} 1
+# HELP events_status_state Current state of a status as the value of the connector.v1.State enum
+# TYPE events_status_state gauge
+events_status_state{id="dbHealth",stream_id=""} 4
+events_status_state{id="dbHealth",stream_id="stream1"} 5
+`
+		err := testutil.GatherAndCompare(promRegistry, strings.NewReader(expected),
+			"events_active_alerts", "events_alert_publications", "events_status_state")
+		assert.NoError(t, err)
+	})
+
+	t.Run("registries can share a registerer", func(t *testing.T) {
+		promRegistry := prometheus.NewRegistry()
+		first := NewRegistry(RegistryWithMetrics(promRegistry))
+		second := NewRegistry(RegistryWithMetrics(promRegistry))
+		assert.Same(t, first.metrics.alertPublications, second.metrics.alertPublications)
+	})
+}
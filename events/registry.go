@@ -3,44 +3,189 @@ package events
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/internal"
 )
 
+// RegistryOpts defines the type for the functional options for creating a registry
+type RegistryOpts func(*Registry)
+
+// defaultExpiryInterval is how often the registry removes expired events by default
+const defaultExpiryInterval = 10 * time.Second
+
+// RegistryWithEventTTL sets the default time after which a published event expires unless it is published again.
+// Events never expire by default. Expired events are removed, notifying the sinks, when events are scraped and by
+// a background ticker of the registry, which is stopped by closing the registry.
+func RegistryWithEventTTL(ttl time.Duration) RegistryOpts {
+	return func(reg *Registry) {
+		reg.ttl = ttl
+	}
+}
+
+// RegistryWithExpiryInterval sets how often the registry removes expired events in the background.
+// Non-positive intervals are ignored.
+func RegistryWithExpiryInterval(interval time.Duration) RegistryOpts {
+	return func(reg *Registry) {
+		if interval > 0 {
+			reg.expiryInterval = interval
+		}
+	}
+}
+
 // Registry enables registering events that need to be published. It also implements the GetEvents
 // method required for fulfilling the data connector contract. This ensures that an embedded registry object
 // provides everything a connector needs when it comes to handling events
 type Registry struct {
-	alerts   map[string]*alertImpl
-	statuses map[string]*statusImpl
-	events   map[string]interface{}
-	rwLock   sync.RWMutex
+	alerts           map[string]Alert
+	statuses         map[string]Status
+	events           map[string]*eventEntry
+	tombstones       map[string]*eventEntry
+	histories        map[string]*historyRing
+	historySize      int
+	historyRetention time.Duration
+	retired          map[string]bool
+	streams          map[string]bool
+	ttl              time.Duration
+	delivery         DeliveryMode
+	maxEvents        int
+	maxEventBytes    int
+	childAlertMode   ChildAlertMode
+	metrics          *registryMetrics
+	sinks            []EventSink
+	queued           []EventChange
+	notifying        bool
+	notifyLock       sync.Mutex
+	store            SnapshotStore
+	saves            chan struct{}
+	now              func() time.Time
+	rwLock           sync.RWMutex
+
+	escalationInterval time.Duration
+	escalating         bool
+	expiryInterval     time.Duration
+	expiring           bool
+	done               chan struct{}
+	closed             bool
+	background         sync.WaitGroup
+}
+
+// eventEntry is an event currently held by the registry
+type eventEntry struct {
+	// event is either a *connectorpb.Alert or a *connectorpb.Status
+	event       interface{}
+	firstSeen   time.Time
+	lastSeen    time.Time
+	occurrences int
+	expiresAt   time.Time
+	pending     *pendingChange
+	limiter     *internal.TokenBucket
+	delivery    DeliveryMode
+	escalation  *escalation
+	// delivered is set once the event has been returned by GetEvents and reset when it changes
+	delivered bool
+	// restored is set for an event restored from a snapshot until the config of its alert or status is applied
+	restored bool
+}
+
+// expired reports whether the ttl of the entry has elapsed at the given time
+func (entry *eventEntry) expired(now time.Time) bool {
+	return !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt)
+}
+
+// publication is an event published by an alert or a status along with how it has to be added
+type publication struct {
+	event  interface{}
+	ttl    time.Duration
+	config eventConfig
 }
 
 // NewRegistry creates a new events registry for use in a data connector
-func NewRegistry() *Registry {
-	return &Registry{
-		rwLock:   sync.RWMutex{},
-		events:   make(map[string]interface{}),
-		alerts:   make(map[string]*alertImpl),
-		statuses: make(map[string]*statusImpl),
+func NewRegistry(opts ...RegistryOpts) *Registry {
+	reg := &Registry{
+		rwLock:           sync.RWMutex{},
+		events:           make(map[string]*eventEntry),
+		tombstones:       make(map[string]*eventEntry),
+		histories:        make(map[string]*historyRing),
+		retired:          make(map[string]bool),
+		streams:          make(map[string]bool),
+		historySize:      defaultHistorySize,
+		historyRetention: defaultHistoryRetention,
+		alerts:           make(map[string]Alert),
+		statuses:         make(map[string]Status),
+		metrics:          newRegistryMetrics(),
+		now:              time.Now,
+
+		escalationInterval: defaultEscalationInterval,
+		expiryInterval:     defaultExpiryInterval,
+		done:               make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(reg)
+	}
+	if reg.store != nil {
+		reg.restore()
+		reg.saves = make(chan struct{}, 1)
+		reg.saveInBackground()
 	}
+	return reg
 }
 
-// RegisterAlert registers an alert event in the registry
-func (reg *Registry) RegisterAlert(alert Alert) {
-	a := alert.(*alertImpl)
-	reg.alerts[a.name] = a
-	a.registry = reg
+// RegisterAlert registers an alert event in the registry. Registering an alert with
+// the name of an already registered alert fails with ErrDuplicateEvent.
+func (reg *Registry) RegisterAlert(alert Alert) error {
+	if alert == nil {
+		return fmt.Errorf("alert must not be nil")
+	}
+	name := alert.Name()
+
+	reg.rwLock.Lock()
+	if _, ok := reg.alerts[name]; ok {
+		reg.rwLock.Unlock()
+		return fmt.Errorf("unable to register alert %s: %w", name, ErrDuplicateEvent)
+	}
+	reg.alerts[name] = alert
+	reg.rwLock.Unlock()
+
+	if err := alert.Bind(reg); err != nil {
+		reg.rwLock.Lock()
+		delete(reg.alerts, name)
+		reg.rwLock.Unlock()
+		return fmt.Errorf("unable to register alert %s: %w", name, err)
+	}
+	reg.configureRestored("alert", alert)
+	return nil
 }
 
-// RegisterStatus registers a status event in the registry
-func (reg *Registry) RegisterStatus(status Status) {
-	s := status.(*statusImpl)
-	reg.statuses[s.name] = s
-	s.registry = reg
+// RegisterStatus registers a status event in the registry. Registering a status with
+// the name of an already registered status fails with ErrDuplicateEvent.
+func (reg *Registry) RegisterStatus(status Status) error {
+	if status == nil {
+		return fmt.Errorf("status must not be nil")
+	}
+	name := status.Name()
+
+	reg.rwLock.Lock()
+	if _, ok := reg.statuses[name]; ok {
+		reg.rwLock.Unlock()
+		return fmt.Errorf("unable to register status %s: %w", name, ErrDuplicateEvent)
+	}
+	reg.statuses[name] = status
+	reg.rwLock.Unlock()
+
+	if err := status.Bind(reg); err != nil {
+		reg.rwLock.Lock()
+		delete(reg.statuses, name)
+		reg.rwLock.Unlock()
+		return fmt.Errorf("unable to register status %s: %w", name, err)
+	}
+	reg.configureRestored("status", status)
+	return nil
 }
 
 // GetEvents implements the GetEvents method required for fulfilling data connector contract
@@ -55,37 +200,225 @@ func (reg *Registry) GetEvents(context.Context, *connectorpb.GetEventsRequest) (
 	return resp, nil
 }
 
-func (reg *Registry) addEvent(event interface{}) {
-	reg.rwLock.Lock()
-	defer reg.rwLock.Unlock()
-	var key string
+// Statuses returns a copy of the statuses currently held by the registry
+func (reg *Registry) Statuses() []*connectorpb.Status {
+	reg.rwLock.RLock()
+	defer reg.rwLock.RUnlock()
+
+	now := reg.now()
+	statuses := make([]*connectorpb.Status, 0)
+	for _, entry := range reg.events {
+		if status, ok := entry.event.(*connectorpb.Status); ok && !entry.expired(now) {
+			statuses = append(statuses, proto.Clone(status).(*connectorpb.Status))
+		}
+	}
+	return statuses
+}
+
+// ClearStream removes all alerts and statuses published for the stream and returns the number of removed events
+func (reg *Registry) ClearStream(streamID string) int {
+	changes := reg.deleteEvents(true, func(_ string, entry *eventEntry) bool {
+		return eventStreamID(entry.event) == streamID
+	})
+	reg.notify()
+	return len(changes)
+}
+
+func alertKey(id string, streamID string) string {
+	return "alert/" + id + "/" + streamID
+}
+
+func statusKey(id string, streamID string) string {
+	return "status/" + id + "/" + streamID
+}
+
+func eventKey(event interface{}) string {
 	switch event := event.(type) {
 	case *connectorpb.Alert:
-		key = event.Id + event.StreamId
+		return alertKey(event.Id, event.StreamId)
 	case *connectorpb.Status:
-		key = event.Id + event.StreamId
+		return statusKey(event.Id, event.StreamId)
 	}
+	return ""
+}
 
-	reg.events[key] = event
-	return
+func eventTypeName(event interface{}) string {
+	switch event.(type) {
+	case *connectorpb.Alert:
+		return "alert"
+	case *connectorpb.Status:
+		return "status"
+	}
+	return ""
 }
 
-func (reg *Registry) getAllEvents() (events []interface{}) {
-	reg.rwLock.RLock()
-	defer reg.rwLock.RUnlock()
+func eventID(event interface{}) string {
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		return event.Id
+	case *connectorpb.Status:
+		return event.Id
+	}
+	return ""
+}
+
+func eventStreamID(event interface{}) string {
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		return event.StreamId
+	case *connectorpb.Status:
+		return event.StreamId
+	}
+	return ""
+}
 
-	for _, event := range reg.events {
-		events = append(events, event)
+// addEvent adds or replaces the published event. A positive ttl overrides the default ttl of the registry.
+// Repeated publications of an event keep the creation time of the first one.
+func (reg *Registry) addEvent(pub *publication) {
+	if reg.storeEvent(pub) {
+		reg.notify()
 	}
-	return events
+}
+
+// storeEvent stores the published event, queues the resulting change and reports whether there was one
+func (reg *Registry) storeEvent(pub *publication) bool {
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+
+	now := reg.now()
+	event, ttl := pub.event, pub.ttl
+	key := eventKey(event)
+	if streamID := eventStreamID(event); reg.retired[streamID] {
+		glog.Warningf("ignoring %s %s published for retired stream %s", eventTypeName(event), eventID(event), streamID)
+		return false
+	}
+	change := EventChange{
+		Type:  EventPublished,
+		Time:  now,
+		Event: event,
+	}
+	entry, ok := reg.events[key]
+	if alert, isAlert := event.(*connectorpb.Alert); ok && isAlert && entry.escalation != nil {
+		entry.escalation.apply(alert)
+	}
+	if ok && !reg.admit(entry, pub, now) {
+		return false
+	}
+	if ok {
+		change.Type = EventUpdated
+		change.Previous = entry.event
+	} else {
+		entry = &eventEntry{
+			event:     event,
+			firstSeen: now,
+		}
+		reg.configureEntry(entry, pub.config)
+		reg.events[key] = entry
+	}
+	delete(reg.tombstones, key)
+	if streamID := eventStreamID(event); streamID != "" {
+		reg.streams[streamID] = true
+	}
+	if !sameEvent(entry.event, event) {
+		entry.delivered = false
+	}
+	entry.event = event
+	entry.lastSeen = now
+	entry.delivery = reg.deliveryMode(pub)
+	entry.occurrences++
+	entry.expiresAt = time.Time{}
+	if ttl <= 0 {
+		ttl = reg.ttl
+	}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+		reg.startExpiry()
+	}
+
+	stampEvent(entry)
+	reg.recordOccurrence(key, event, now)
+	reg.metrics.observe(change)
+	reg.queueChanges(change)
+	return true
+}
+
+// removeEvent removes the event stored under the key and reports whether it was present
+func (reg *Registry) removeEvent(key string) bool {
+	reg.rwLock.Lock()
+	entry, ok := reg.events[key]
+	delete(reg.events, key)
+	now := reg.now()
+	if ok {
+		reg.buryEvent(key, entry, now)
+		reg.queueChanges(EventChange{
+			Type:  EventResolved,
+			Time:  now,
+			Event: entry.event,
+		})
+	}
+	reg.rwLock.Unlock()
+
+	reg.notify()
+	return ok
+}
+
+// deleteEvents removes all events matched by the function and returns the resulting changes, which are queued
+// for the sinks if notify is set
+func (reg *Registry) deleteEvents(notify bool, match func(key string, entry *eventEntry) bool) []EventChange {
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+
+	now := reg.now()
+	var changes []EventChange
+	for key, entry := range reg.events {
+		if !match(key, entry) {
+			continue
+		}
+		delete(reg.events, key)
+		reg.buryEvent(key, entry, now)
+		changes = append(changes, EventChange{
+			Type:  EventResolved,
+			Time:  now,
+			Event: entry.event,
+		})
+	}
+	if notify {
+		reg.queueChanges(changes...)
+	}
+	return changes
+}
+
+// startExpiry starts the background ticker removing expired events unless it is running or the registry is closed.
+// The caller must hold the write lock.
+func (reg *Registry) startExpiry() {
+	if reg.expiring || reg.closed {
+		return
+	}
+	reg.expiring = true
+	reg.runInBackground(reg.expiryInterval, reg.expireEvents)
+}
+
+// expireEvents removes all events whose ttl has elapsed
+func (reg *Registry) expireEvents() {
+	now := reg.now()
+	reg.deleteEvents(true, func(_ string, entry *eventEntry) bool {
+		return entry.expired(now)
+	})
+	reg.notify()
+}
+
+func (reg *Registry) getAllEvents() (events []interface{}) {
+	reg.expireEvents()
+	reg.pruneHistories()
+	return reg.deliverEvents()
 }
 
 func (reg *Registry) logAllEvents() {
 	reg.rwLock.RLock()
 	defer reg.rwLock.RUnlock()
 
-	for key, event := range reg.events {
-		glog.Infof("[%s] event: => %#v", key, event)
+	for key, entry := range reg.events {
+		glog.Infof("[%s] event: => %#v", key, entry.event)
 	}
 }
 
@@ -93,7 +426,8 @@ func (reg *Registry) clearCache() {
 	reg.rwLock.Lock()
 	defer reg.rwLock.Unlock()
 
-	reg.events = make(map[string]interface{})
+	reg.events = make(map[string]*eventEntry)
+	reg.tombstones = make(map[string]*eventEntry)
 
 	return
 }
@@ -103,17 +437,39 @@ func (reg *Registry) getAllConnectorEvents() []*connectorpb.EventPayload {
 
 	eventPayloads := make([]*connectorpb.EventPayload, 0)
 	for _, v := range events {
-		switch event := v.(type) {
-		case *connectorpb.Alert:
-			alertEventPayload := &connectorpb.EventPayload_Alert{Alert: event}
-			eventPayload := &connectorpb.EventPayload{Object: alertEventPayload}
-			eventPayloads = append(eventPayloads, eventPayload)
-		case *connectorpb.Status:
-			alertEventPayload := &connectorpb.EventPayload_Status{Status: event}
-			eventPayload := &connectorpb.EventPayload{Object: alertEventPayload}
+		if eventPayload := toEventPayload(v); eventPayload != nil {
 			eventPayloads = append(eventPayloads, eventPayload)
 		}
 	}
 
 	return eventPayloads
 }
+
+func toEventPayload(event interface{}) *connectorpb.EventPayload {
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		alertEventPayload := &connectorpb.EventPayload_Alert{Alert: event}
+		return &connectorpb.EventPayload{Object: alertEventPayload}
+	case *connectorpb.Status:
+		statusEventPayload := &connectorpb.EventPayload_Status{Status: event}
+		return &connectorpb.EventPayload{Object: statusEventPayload}
+	}
+	return nil
+}
+
+// configuredEvent is implemented by the alerts and statuses created by the SDK, which carry the config set with
+// their EventOpts
+type configuredEvent interface {
+	publishConfig() eventConfig
+}
+
+// configureEntry sets up the rate limiter and the escalation of a new entry. The caller must hold the write lock.
+func (reg *Registry) configureEntry(entry *eventEntry, cfg eventConfig) {
+	if cfg.rateLimit > 0 {
+		entry.limiter = internal.NewTokenBucketWithClock(cfg.rateLimit, float64(cfg.burst), reg.now)
+	}
+	if alert, isAlert := entry.event.(*connectorpb.Alert); isAlert && cfg.escalateAfter > 0 {
+		entry.escalation = restoreEscalation(alert, cfg.escalateAfter, entry.firstSeen)
+		reg.startEscalation()
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+func getEventPayloads(t *testing.T, reg *Registry) []*connectorpb.EventPayload {
+	resp, err := reg.GetEvents(context.Background(), &connectorpb.GetEventsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, connectorpb.ResponseCode_RESPONSE_CODE_OK, resp.GetStatus().GetCode())
+	return resp.GetEventPayloads()
+}
+
+// fakeAlert is an Alert implementation that records its publications
+type fakeAlert struct {
+	name      string
+	registry  *Registry
+	published int
+}
+
+func (a *fakeAlert) Name() string                  { return a.name }
+func (a *fakeAlert) Bind(registry *Registry) error { a.registry = registry; return nil }
+func (a *fakeAlert) Publish(...AlertOpts) error    { a.published++; return nil }
+func (a *fakeAlert) Resolve(...AlertOpts) error    { return nil }
+
+func TestRegistry(t *testing.T) {
+	newAlert := func() Alert {
+		return NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	}
+	newStatus := func() Status {
+		return NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY)
+	}
+
+	t.Run("publishing an unregistered event fails", func(t *testing.T) {
+		assert.Error(t, newAlert().Publish())
+		assert.Error(t, newAlert().Resolve())
+		assert.Error(t, newStatus().Publish())
+		assert.Error(t, newStatus().Clear())
+	})
+
+	t.Run("published events are returned until resolved or cleared", func(t *testing.T) {
+		reg := NewRegistry()
+		alert := newAlert()
+		status := newStatus()
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, alert.Publish())
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, status.Publish(StatusWithStreamID("stream1")))
+		assert.Len(t, getEventPayloads(t, reg), 3)
+
+		require.NoError(t, alert.Resolve(AlertWithStreamID("stream1")))
+		payloads := getEventPayloads(t, reg)
+		assert.Len(t, payloads, 2)
+
+		require.NoError(t, status.Clear(StatusWithStreamID("stream1")))
+		payloads = getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.Equal(t, "", payloads[0].GetAlert().GetStreamId())
+
+		require.NoError(t, alert.Resolve())
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+
+	t.Run("clearing a stream removes only the events of that stream", func(t *testing.T) {
+		reg := NewRegistry()
+		alert := newAlert()
+		status := newStatus()
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, status.Publish(StatusWithStreamID("stream1")))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream2")))
+
+		assert.Equal(t, 2, reg.ClearStream("stream1"))
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.Equal(t, "stream2", payloads[0].GetAlert().GetStreamId())
+	})
+
+	t.Run("events expire after their ttl", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry(RegistryWithEventTTL(time.Minute))
+		reg.now = clock.now
+		alert := newAlert()
+		status := newStatus()
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, alert.Publish())
+		require.NoError(t, status.Publish(StatusWithTTL(time.Hour)))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+
+		clock.advance(time.Minute)
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		assert.NotNil(t, payloads[0].GetStatus())
+
+		clock.advance(time.Hour)
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+
+	t.Run("expired events are removed without being scraped", func(t *testing.T) {
+		sink := &recordingSink{}
+		reg := NewRegistry(RegistryWithSinks(sink), RegistryWithExpiryInterval(time.Millisecond))
+		defer reg.Close()
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish(AlertWithTTL(10*time.Millisecond)))
+		assert.Eventually(t, func() bool {
+			types := sink.types()
+			return len(types) == 2 && types[1] == EventResolved
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("registering duplicate names fails", func(t *testing.T) {
+		reg := NewRegistry()
+		require.NoError(t, reg.RegisterAlert(newAlert()))
+		require.NoError(t, reg.RegisterStatus(newStatus()))
+
+		assert.ErrorIs(t, reg.RegisterAlert(newAlert()), ErrDuplicateEvent)
+		assert.ErrorIs(t, reg.RegisterStatus(newStatus()), ErrDuplicateEvent)
+		assert.Error(t, reg.RegisterAlert(nil))
+		assert.Error(t, reg.RegisterStatus(nil))
+	})
+
+	t.Run("an event can only be bound to one registry", func(t *testing.T) {
+		alert := newAlert()
+		require.NoError(t, NewRegistry().RegisterAlert(alert))
+
+		reg := NewRegistry()
+		assert.Error(t, reg.RegisterAlert(alert))
+		assert.NoError(t, reg.RegisterAlert(newAlert()))
+	})
+
+	t.Run("custom alert implementations can be registered", func(t *testing.T) {
+		reg := NewRegistry()
+		alert := &fakeAlert{name: "fake"}
+		require.NoError(t, reg.RegisterAlert(alert))
+		assert.Same(t, reg, alert.registry)
+
+		require.NoError(t, alert.Publish())
+		assert.Equal(t, 1, alert.published)
+		assert.ErrorIs(t, reg.RegisterAlert(&fakeAlert{name: "fake"}), ErrDuplicateEvent)
+	})
+
+	t.Run("events can be registered and published concurrently", func(t *testing.T) {
+		reg := NewRegistry()
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				alert := NewAlert(fmt.Sprintf("alert%d", i), "alert", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY)
+				status := NewStatus(fmt.Sprintf("status%d", i), "status", connectorpb.State_STATE_HEALTHY)
+				assert.NoError(t, reg.RegisterAlert(alert))
+				assert.NoError(t, reg.RegisterStatus(status))
+				assert.NoError(t, alert.Publish())
+				assert.NoError(t, status.Publish())
+			}(i)
+		}
+		wg.Wait()
+		assert.Len(t, getEventPayloads(t, reg), 40)
+	})
+}
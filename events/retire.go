@@ -0,0 +1,107 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"fmt"
+	"sort"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+)
+
+// RetireMode controls what happens to the events of a retired stream
+type RetireMode int
+
+const (
+	// RetireResolve resolves the events of the stream, notifying the sinks
+	RetireResolve RetireMode = iota
+	// RetireDrop drops the events of the stream along with their history without notifying the sinks
+	RetireDrop
+)
+
+// String returns the name of the retire mode
+func (m RetireMode) String() string {
+	switch m {
+	case RetireResolve:
+		return "resolve"
+	case RetireDrop:
+		return "drop"
+	}
+	return fmt.Sprintf("RetireMode(%d)", int(m))
+}
+
+// RetireStream removes all events of a stream that has been removed from the connector and returns the number of
+// removed events. Events published for the stream afterwards are ignored until the stream is reconciled again.
+// Connector scoped events cannot be retired.
+func (reg *Registry) RetireStream(streamID string, mode RetireMode) int {
+	if streamID == "" {
+		return 0
+	}
+	reg.rwLock.Lock()
+	reg.retired[streamID] = true
+	delete(reg.streams, streamID)
+	reg.rwLock.Unlock()
+
+	changes := reg.deleteEvents(mode == RetireResolve, func(_ string, entry *eventEntry) bool {
+		return eventStreamID(entry.event) == streamID
+	})
+	if mode == RetireResolve {
+		reg.notify()
+		return len(changes)
+	}
+
+	reg.rwLock.Lock()
+	for _, change := range changes {
+		delete(reg.histories, eventKey(change.Event))
+	}
+	reg.rwLock.Unlock()
+	if len(changes) > 0 {
+		reg.persist()
+	}
+	return len(changes)
+}
+
+// ReconcileStreams retires every stream the registry has seen events for that is not one of the given streams,
+// including streams without active events, and accepts events for the given streams again if they had been retired.
+// It returns the sorted IDs of the retired streams.
+func (reg *Registry) ReconcileStreams(streamIDs []string, mode RetireMode) []string {
+	current := make(map[string]bool, len(streamIDs))
+	for _, streamID := range streamIDs {
+		current[streamID] = true
+	}
+
+	reg.rwLock.Lock()
+	for streamID := range current {
+		delete(reg.retired, streamID)
+	}
+	retired := make([]string, 0)
+	for streamID := range reg.streams {
+		if !current[streamID] {
+			retired = append(retired, streamID)
+		}
+	}
+	reg.rwLock.Unlock()
+
+	sort.Strings(retired)
+	for _, streamID := range retired {
+		reg.RetireStream(streamID, mode)
+	}
+	return retired
+}
+
+// ReconcilePayloads reconciles the streams of the registry with the stream payloads of a SetPayload request, which
+// are the streams the connector has to serve. A request without stream payloads, e.g. one that only updates the
+// config, leaves the streams unchanged. It returns the IDs of the retired streams.
+func (reg *Registry) ReconcilePayloads(req *connectorpb.SetPayloadRequest, mode RetireMode) []string {
+	var streamIDs []string
+	hasStreams := false
+	for _, payload := range req.GetPayloads() {
+		if stream := payload.GetStream(); stream != nil {
+			hasStreams = true
+			streamIDs = append(streamIDs, stream.GetId())
+		}
+	}
+	if !hasStreams {
+		return nil
+	}
+	return reg.ReconcileStreams(streamIDs, mode)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetireStream(t *testing.T) {
+	newRegistry := func(t *testing.T, sinks ...EventSink) (*Registry, Alert, Status) {
+		reg := NewRegistry(RegistryWithSinks(sinks...))
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		status := NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+		require.NoError(t, alert.Publish())
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, status.Publish(StatusWithStreamID("stream1")))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream2")))
+		return reg, alert, status
+	}
+
+	t.Run("resolving a retired stream notifies the sinks", func(t *testing.T) {
+		sink := &recordingSink{}
+		reg, alert, _ := newRegistry(t, sink)
+
+		assert.Equal(t, 2, reg.RetireStream("stream1", RetireResolve))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+		assert.Equal(t, []ChangeType{EventPublished, EventPublished, EventPublished, EventPublished, EventResolved, EventResolved}, sink.types())
+		assert.NotEmpty(t, reg.AlertHistory("unableToFetchData", "stream1"))
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+		assert.Equal(t, 0, reg.RetireStream("", RetireResolve))
+	})
+
+	t.Run("dropping a retired stream removes its history silently", func(t *testing.T) {
+		sink := &recordingSink{}
+		reg, _, _ := newRegistry(t, sink)
+
+		assert.Equal(t, 2, reg.RetireStream("stream1", RetireDrop))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+		assert.Len(t, sink.types(), 4)
+		assert.Empty(t, reg.AlertHistory("unableToFetchData", "stream1"))
+	})
+
+	t.Run("reconciling set payloads retires the removed streams", func(t *testing.T) {
+		reg, alert, _ := newRegistry(t)
+		reg.RetireStream("stream3", RetireResolve)
+
+		req := &connectorpb.SetPayloadRequest{Payloads: []*connectorpb.Payload{
+			{Object: &connectorpb.Payload_Stream{Stream: &connectorpb.Stream{Id: "stream2"}}},
+			{Object: &connectorpb.Payload_Stream{Stream: &connectorpb.Stream{Id: "stream3"}}},
+			{Object: &connectorpb.Payload_Config{Config: &connectorpb.Config{}}},
+		}}
+		assert.Equal(t, []string{"stream1"}, reg.ReconcilePayloads(req, RetireResolve))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream3")))
+		assert.Len(t, getEventPayloads(t, reg), 3)
+
+		configOnly := &connectorpb.SetPayloadRequest{Payloads: []*connectorpb.Payload{
+			{Object: &connectorpb.Payload_Config{Config: &connectorpb.Config{}}},
+		}}
+		assert.Empty(t, reg.ReconcilePayloads(configOnly, RetireResolve))
+		assert.Len(t, getEventPayloads(t, reg), 3)
+	})
+
+	t.Run("reconciling retires removed streams without active events", func(t *testing.T) {
+		reg, alert, status := newRegistry(t)
+		require.NoError(t, alert.Resolve(AlertWithStreamID("stream1")))
+		require.NoError(t, status.Clear(StatusWithStreamID("stream1")))
+
+		assert.Equal(t, []string{"stream1"}, reg.ReconcileStreams([]string{"stream2"}, RetireResolve))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		assert.Len(t, getEventPayloads(t, reg), 2)
+		assert.Empty(t, reg.ReconcileStreams([]string{"stream2"}, RetireResolve))
+	})
+}
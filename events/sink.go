@@ -0,0 +1,181 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ChangeType describes how an event held by a registry changed
+type ChangeType int
+
+const (
+	// EventPublished is the first publication of an event
+	EventPublished ChangeType = iota
+	// EventUpdated is a republication of an event that is already held by the registry
+	EventUpdated
+	// EventResolved is the removal of an event by resolving, clearing or expiry
+	EventResolved
+)
+
+// String returns the name of the change type
+func (c ChangeType) String() string {
+	switch c {
+	case EventPublished:
+		return "published"
+	case EventUpdated:
+		return "updated"
+	case EventResolved:
+		return "resolved"
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(c))
+}
+
+// EventChange is a change of an event held by a registry
+type EventChange struct {
+	Type ChangeType
+	Time time.Time
+	// Event is either a *connectorpb.Alert or a *connectorpb.Status
+	Event interface{}
+	// Previous is the event that was replaced by an update
+	Previous interface{}
+}
+
+// Alert returns the changed alert, or nil if a status changed
+func (c EventChange) Alert() *connectorpb.Alert {
+	alert, _ := c.Event.(*connectorpb.Alert)
+	return alert
+}
+
+// Status returns the changed status, or nil if an alert changed
+func (c EventChange) Status() *connectorpb.Status {
+	status, _ := c.Event.(*connectorpb.Status)
+	return status
+}
+
+// MarshalJSON encodes the change with the event in its protobuf JSON representation
+func (c EventChange) MarshalJSON() ([]byte, error) {
+	event, err := protojson.Marshal(toEventPayload(c.Event))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Change string          `json:"change"`
+		Time   time.Time       `json:"time"`
+		Event  json.RawMessage `json:"event"`
+	}{
+		Change: c.Type.String(),
+		Time:   c.Time,
+		Event:  event,
+	})
+}
+
+// EventSink is notified of every change of the events held by a registry, one change at a time and
+// in the order the changes were made. Notify is called after the registry has been updated, from the
+// goroutine that caused the change or from one notifying earlier changes, so sinks doing slow work
+// such as network calls should hand it off.
+type EventSink interface {
+	Notify(change EventChange)
+}
+
+// RegistryWithSinks adds sinks that are notified of every change of the events in the registry
+func RegistryWithSinks(sinks ...EventSink) RegistryOpts {
+	return func(reg *Registry) {
+		reg.sinks = append(reg.sinks, sinks...)
+	}
+}
+
+// AddSink adds a sink that is notified of every subsequent change of the events in the registry
+func (reg *Registry) AddSink(sink EventSink) {
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+
+	reg.sinks = append(reg.sinks, sink)
+}
+
+// queueChanges queues the changes for the sinks. The caller must hold the write lock, so that the changes are
+// queued in the order they were made.
+func (reg *Registry) queueChanges(changes ...EventChange) {
+	if len(changes) == 0 {
+		return
+	}
+	reg.notifyLock.Lock()
+	defer reg.notifyLock.Unlock()
+
+	reg.queued = append(reg.queued, changes...)
+}
+
+// notify hands the queued changes to the sinks in order. Only one goroutine notifies the sinks at a time;
+// changes queued meanwhile, including by the sinks themselves, are handed on by that goroutine.
+func (reg *Registry) notify() {
+	reg.notifyLock.Lock()
+	if reg.notifying {
+		reg.notifyLock.Unlock()
+		return
+	}
+	reg.notifying = true
+	for len(reg.queued) > 0 {
+		changes := reg.queued
+		reg.queued = nil
+		reg.notifyLock.Unlock()
+
+		reg.persist()
+		reg.rwLock.RLock()
+		sinks := reg.sinks
+		reg.rwLock.RUnlock()
+		for _, change := range changes {
+			for _, sink := range sinks {
+				sink.Notify(change)
+			}
+		}
+		reg.notifyLock.Lock()
+	}
+	reg.notifying = false
+	reg.notifyLock.Unlock()
+}
+
+type logSink struct{}
+
+// NewLogSink creates a sink that logs every change
+func NewLogSink() EventSink {
+	return logSink{}
+}
+
+// Notify logs the change
+func (logSink) Notify(change EventChange) {
+	switch event := change.Event.(type) {
+	case *connectorpb.Alert:
+		glog.Infof("[%s] alert %s [stream: %s][message: %s][severity: %s][state: %s]",
+			change.Type, event.Id, event.StreamId, event.Message, event.Severity, event.State)
+	case *connectorpb.Status:
+		glog.Infof("[%s] status %s [stream: %s][message: %s][state: %s]",
+			change.Type, event.Id, event.StreamId, event.Message, event.State)
+	}
+}
+
+type metricsSink struct {
+	changes *prometheus.CounterVec
+}
+
+// NewMetricsSink creates a sink that counts the changes in the `events_changes` metric
+// registered with the prometheus registerer
+func NewMetricsSink(registerer prometheus.Registerer) EventSink {
+	changes := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_changes",
+		Help: "Number of changes of the events held by the registry",
+	}, []string{"type", "id", "change"})
+	return &metricsSink{
+		changes: registerCounterVec(registerer, changes),
+	}
+}
+
+// Notify counts the change
+func (s *metricsSink) Notify(change EventChange) {
+	s.changes.WithLabelValues(eventTypeName(change.Event), eventID(change.Event), change.Type.String()).Inc()
+}
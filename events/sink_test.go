@@ -0,0 +1,198 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	lock    sync.Mutex
+	changes []EventChange
+}
+
+func (s *recordingSink) Notify(change EventChange) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.changes = append(s.changes, change)
+}
+
+func (s *recordingSink) types() []ChangeType {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var types []ChangeType
+	for _, change := range s.changes {
+		types = append(types, change.Type)
+	}
+	return types
+}
+
+type sinkFunc func(change EventChange)
+
+func (f sinkFunc) Notify(change EventChange) {
+	f(change)
+}
+
+func TestEventSinks(t *testing.T) {
+	newAlert := func() Alert {
+		return NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	}
+
+	t.Run("sinks are notified of every change", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		sink := &recordingSink{}
+		reg := NewRegistry(RegistryWithSinks(sink, NewLogSink()))
+		reg.now = clock.now
+		alert := newAlert()
+		status := NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, alert.Publish())
+		require.NoError(t, alert.Publish())
+		require.NoError(t, alert.Resolve())
+		require.NoError(t, alert.Resolve())
+		require.NoError(t, status.Publish(StatusWithStreamID("stream1")))
+		assert.Equal(t, 1, reg.ClearStream("stream1"))
+		require.NoError(t, status.Publish(StatusWithTTL(time.Second)))
+		clock.advance(time.Second)
+		assert.Empty(t, getEventPayloads(t, reg))
+
+		assert.Equal(t, []ChangeType{
+			EventPublished, EventUpdated, EventResolved,
+			EventPublished, EventResolved,
+			EventPublished, EventResolved,
+		}, sink.types())
+		assert.NotNil(t, sink.changes[1].Previous)
+		assert.NotNil(t, sink.changes[1].Alert())
+		assert.NotNil(t, sink.changes[3].Status())
+	})
+
+	t.Run("sinks see changes in the order they were made", func(t *testing.T) {
+		reg := NewRegistry()
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+		reg.AddSink(sinkFunc(func(change EventChange) {
+			if change.Type == EventPublished {
+				assert.NoError(t, alert.Resolve())
+			}
+		}))
+		sink := &recordingSink{}
+		reg.AddSink(sink)
+
+		require.NoError(t, alert.Publish())
+		assert.Equal(t, []ChangeType{EventPublished, EventResolved}, sink.types())
+		assert.Empty(t, getEventPayloads(t, reg))
+	})
+
+	t.Run("concurrent changes reach the sinks in the order of the registry", func(t *testing.T) {
+		reg := NewRegistry()
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+		sink := &recordingSink{}
+		reg.AddSink(sink)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					assert.NoError(t, alert.Publish())
+					assert.NoError(t, alert.Resolve())
+				}
+			}()
+		}
+		wg.Wait()
+
+		active := false
+		for i, changeType := range sink.types() {
+			assert.Equal(t, changeType != EventPublished, active, "change %d", i)
+			active = changeType != EventResolved
+		}
+		assert.False(t, active)
+	})
+
+	t.Run("metrics sink counts changes", func(t *testing.T) {
+		sink := NewMetricsSink(prometheus.NewRegistry())
+		reg := NewRegistry(RegistryWithSinks(sink))
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish())
+		require.NoError(t, alert.Publish())
+		changes := sink.(*metricsSink).changes
+		assert.Equal(t, float64(1), testutil.ToFloat64(changes.WithLabelValues("alert", "unableToFetchData", "published")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(changes.WithLabelValues("alert", "unableToFetchData", "updated")))
+	})
+
+	t.Run("webhook sink posts changes as json", func(t *testing.T) {
+		var lock sync.Mutex
+		var bodies []map[string]interface{}
+		var headers []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(data, &body))
+
+			lock.Lock()
+			defer lock.Unlock()
+			bodies = append(bodies, body)
+			headers = append(headers, r.Header.Get("Authorization"))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		sink := NewWebhookSink(server.URL, WebhookSinkWithHeader("Authorization", "Bearer token"))
+		reg := NewRegistry()
+		reg.AddSink(sink)
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, alert.Resolve(AlertWithStreamID("stream1")))
+		sink.Close()
+		sink.Notify(EventChange{})
+
+		lock.Lock()
+		defer lock.Unlock()
+		require.Len(t, bodies, 2)
+		assert.Equal(t, "published", bodies[0]["change"])
+		assert.Equal(t, "resolved", bodies[1]["change"])
+		event := bodies[0]["event"].(map[string]interface{})["alert"].(map[string]interface{})
+		assert.Equal(t, "unableToFetchData", event["id"])
+		assert.Equal(t, "stream1", event["streamId"])
+		assert.Equal(t, []string{"Bearer token", "Bearer token"}, headers)
+	})
+
+	t.Run("webhook sink survives failing endpoints", func(t *testing.T) {
+		calls := make(chan struct{}, 2)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls <- struct{}{}
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		sink := NewWebhookSink(server.URL)
+		reg := NewRegistry(RegistryWithSinks(sink))
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish())
+		require.NoError(t, alert.Resolve())
+		sink.Close()
+		assert.Len(t, calls, 2)
+	})
+}
@@ -0,0 +1,296 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// restoredAtProp marks an event that was restored from a snapshot until it is published again
+const restoredAtProp = "RestoredAt"
+
+// Snapshot is the state of the events held by a registry at a point in time
+type Snapshot struct {
+	Time   time.Time       `json:"time"`
+	Events []SnapshotEvent `json:"events"`
+}
+
+// SnapshotEvent is an event held by a registry along with its bookkeeping
+type SnapshotEvent struct {
+	// Event is either a *connectorpb.Alert or a *connectorpb.Status
+	Event       interface{}
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	Occurrences int
+	// ExpiresAt is zero for events that do not expire
+	ExpiresAt time.Time
+}
+
+type snapshotEventJSON struct {
+	Event       json.RawMessage `json:"event"`
+	FirstSeen   time.Time       `json:"firstSeen"`
+	LastSeen    time.Time       `json:"lastSeen"`
+	Occurrences int             `json:"occurrences"`
+	ExpiresAt   *time.Time      `json:"expiresAt,omitempty"`
+}
+
+// MarshalJSON encodes the snapshot event with the event in its protobuf JSON representation
+func (e SnapshotEvent) MarshalJSON() ([]byte, error) {
+	event, err := protojson.Marshal(toEventPayload(e.Event))
+	if err != nil {
+		return nil, err
+	}
+	v := snapshotEventJSON{
+		Event:       event,
+		FirstSeen:   e.FirstSeen,
+		LastSeen:    e.LastSeen,
+		Occurrences: e.Occurrences,
+	}
+	if !e.ExpiresAt.IsZero() {
+		v.ExpiresAt = &e.ExpiresAt
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a snapshot event encoded by MarshalJSON
+func (e *SnapshotEvent) UnmarshalJSON(data []byte) error {
+	var v snapshotEventJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	payload := &connectorpb.EventPayload{}
+	if err := protojson.Unmarshal(v.Event, payload); err != nil {
+		return err
+	}
+
+	switch {
+	case payload.GetAlert() != nil:
+		e.Event = payload.GetAlert()
+	case payload.GetStatus() != nil:
+		e.Event = payload.GetStatus()
+	default:
+		return fmt.Errorf("snapshot event is neither an alert nor a status")
+	}
+	e.FirstSeen = v.FirstSeen
+	e.LastSeen = v.LastSeen
+	e.Occurrences = v.Occurrences
+	e.ExpiresAt = time.Time{}
+	if v.ExpiresAt != nil {
+		e.ExpiresAt = *v.ExpiresAt
+	}
+	return nil
+}
+
+// SnapshotStore persists the events held by a registry
+type SnapshotStore interface {
+	// Save replaces the persisted snapshot
+	Save(snapshot *Snapshot) error
+	// Load returns the persisted snapshot, or nil if there is none
+	Load() (*Snapshot, error)
+}
+
+type fileSnapshotStore struct {
+	path string
+}
+
+// NewFileSnapshotStore creates a store that keeps the snapshot as JSON in the file at the path. The file is
+// replaced atomically, so a crash while saving leaves the previous snapshot intact.
+func NewFileSnapshotStore(path string) SnapshotStore {
+	return &fileSnapshotStore{path: path}
+}
+
+// Save writes the snapshot to a temporary file next to the snapshot file and renames it over the snapshot file
+func (s *fileSnapshotStore) Save(snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Load reads the snapshot file, returning nil if it does not exist
+func (s *fileSnapshotStore) Load() (*Snapshot, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RegistryWithSnapshotStore restores the events of the snapshot in the store when creating the registry, and saves
+// a snapshot of the active events to the store after changes. Snapshots are saved in the background, and closing the
+// registry saves the last changes. Restored events are marked with a `RestoredAt` metadata property until they are
+// published again, and get the config of their alert or status, such as the delivery mode, once it is registered.
+func RegistryWithSnapshotStore(store SnapshotStore) RegistryOpts {
+	return func(reg *Registry) {
+		reg.store = store
+	}
+}
+
+// Snapshot returns the state of the events currently held by the registry
+func (reg *Registry) Snapshot() *Snapshot {
+	reg.rwLock.RLock()
+	defer reg.rwLock.RUnlock()
+
+	now := reg.now()
+	snapshot := &Snapshot{
+		Time:   now,
+		Events: make([]SnapshotEvent, 0, len(reg.events)),
+	}
+	for _, entry := range reg.events {
+		if entry.expired(now) {
+			continue
+		}
+		snapshot.Events = append(snapshot.Events, SnapshotEvent{
+			Event:       proto.Clone(entry.event.(proto.Message)),
+			FirstSeen:   entry.firstSeen,
+			LastSeen:    entry.lastSeen,
+			Occurrences: entry.occurrences,
+			ExpiresAt:   entry.expiresAt,
+		})
+	}
+	return snapshot
+}
+
+// restore adds the events of the snapshot in the store that have not expired yet
+func (reg *Registry) restore() {
+	snapshot, err := reg.store.Load()
+	if err != nil {
+		glog.Errorf("unable to load events snapshot: %s", err.Error())
+		return
+	}
+	if snapshot == nil {
+		return
+	}
+
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+
+	now := reg.now()
+	restoredAt := structpb.NewStringValue(now.UTC().Format(time.RFC3339Nano))
+	for _, e := range snapshot.Events {
+		entry := &eventEntry{
+			event:       e.Event,
+			firstSeen:   e.FirstSeen,
+			lastSeen:    e.LastSeen,
+			occurrences: e.Occurrences,
+			expiresAt:   e.ExpiresAt,
+			delivery:    reg.delivery,
+			restored:    true,
+		}
+		if entry.expired(now) {
+			continue
+		}
+		switch event := entry.event.(type) {
+		case *connectorpb.Alert:
+			event.Metadata = withMetadataFields(event.Metadata, map[string]*structpb.Value{restoredAtProp: restoredAt})
+		case *connectorpb.Status:
+			event.Metadata = withMetadataFields(event.Metadata, map[string]*structpb.Value{restoredAtProp: restoredAt})
+		}
+		reg.events[eventKey(entry.event)] = entry
+		if !entry.expiresAt.IsZero() {
+			reg.startExpiry()
+		}
+		if streamID := eventStreamID(entry.event); streamID != "" {
+			reg.streams[streamID] = true
+		}
+	}
+	glog.Infof("restored %d events from snapshot taken at %s", len(reg.events), snapshot.Time)
+}
+
+// configureRestored applies the config of a newly registered alert or status, such as its delivery mode, rate limit
+// and escalation, to its restored events
+func (reg *Registry) configureRestored(eventType string, event Event) {
+	configured, ok := event.(configuredEvent)
+	if !ok {
+		return
+	}
+	cfg := configured.publishConfig()
+
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+	for _, entry := range reg.events {
+		if !entry.restored || eventTypeName(entry.event) != eventType || eventID(entry.event) != event.Name() {
+			continue
+		}
+		entry.restored = false
+		entry.delivery = reg.deliveryMode(&publication{config: cfg})
+		reg.configureEntry(entry, cfg)
+	}
+}
+
+// persist marks the registry as changed, so that the background writer saves a new snapshot to the store, if any.
+// Changes made while a snapshot is being saved are folded into the next one.
+func (reg *Registry) persist() {
+	if reg.store == nil {
+		return
+	}
+	select {
+	case reg.saves <- struct{}{}:
+	default:
+	}
+}
+
+// saveInBackground saves a snapshot whenever the registry has changed until the registry is closed, and the last
+// changes once it is closed. Saving from a single goroutine keeps an older snapshot from overwriting a newer one.
+func (reg *Registry) saveInBackground() {
+	reg.background.Add(1)
+	go func() {
+		defer reg.background.Done()
+		for {
+			select {
+			case <-reg.saves:
+				reg.save()
+			case <-reg.done:
+				select {
+				case <-reg.saves:
+					reg.save()
+				default:
+				}
+				return
+			}
+		}
+	}()
+}
+
+func (reg *Registry) save() {
+	if err := reg.store.Save(reg.Snapshot()); err != nil {
+		glog.Errorf("unable to save events snapshot: %s", err.Error())
+	}
+}
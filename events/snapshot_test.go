@@ -0,0 +1,162 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotStore(t *testing.T) {
+	newAlert := func() Alert {
+		return NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+	}
+	newStatus := func() Status {
+		return NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY)
+	}
+
+	t.Run("active events are restored and marked in a new registry", func(t *testing.T) {
+		store := NewFileSnapshotStore(filepath.Join(t.TempDir(), "events.json"))
+		reg := NewRegistry(RegistryWithSnapshotStore(store))
+		alert := newAlert()
+		status := newStatus()
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, reg.RegisterStatus(status))
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, status.Publish())
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream2")))
+		require.NoError(t, alert.Resolve(AlertWithStreamID("stream2")))
+		history := reg.AlertHistory("unableToFetchData", "stream1")
+		require.NotEmpty(t, history)
+		createdAt := history[0].Time
+		reg.Close()
+
+		restored := NewRegistry(RegistryWithSnapshotStore(store))
+		payloads := getEventPayloads(t, restored)
+		require.Len(t, payloads, 2)
+		for _, payload := range payloads {
+			if alert := payload.GetAlert(); alert != nil {
+				assert.Equal(t, "stream1", alert.GetStreamId())
+				assert.True(t, createdAt.Equal(alert.GetCreatedAt().AsTime()))
+				assert.Equal(t, float64(2), alert.GetMetadata().GetFields()[occurrencesProp].GetNumberValue())
+				assert.Contains(t, alert.GetMetadata().GetFields(), restoredAtProp)
+			} else {
+				assert.Contains(t, payload.GetStatus().GetMetadata().GetFields(), restoredAtProp)
+			}
+		}
+
+		restoredAlert := newAlert()
+		require.NoError(t, restored.RegisterAlert(restoredAlert))
+		require.NoError(t, restoredAlert.Publish(AlertWithStreamID("stream1")))
+		for _, payload := range getEventPayloads(t, restored) {
+			if alert := payload.GetAlert(); alert != nil {
+				assert.NotContains(t, alert.GetMetadata().GetFields(), restoredAtProp)
+				assert.Equal(t, float64(3), alert.GetMetadata().GetFields()[occurrencesProp].GetNumberValue())
+			}
+		}
+	})
+
+	t.Run("expired events are not restored", func(t *testing.T) {
+		store := NewFileSnapshotStore(filepath.Join(t.TempDir(), "events.json"))
+		reg := NewRegistry(RegistryWithSnapshotStore(store))
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, alert.Publish(AlertWithTTL(time.Nanosecond)))
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream1")))
+		reg.Close()
+
+		assert.Len(t, NewRegistry(RegistryWithSnapshotStore(store)).Snapshot().Events, 1)
+	})
+
+	t.Run("a missing or corrupt snapshot leaves the registry empty", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.json")
+		assert.Empty(t, getEventPayloads(t, NewRegistry(RegistryWithSnapshotStore(NewFileSnapshotStore(path)))))
+
+		require.NoError(t, ioutil.WriteFile(path, []byte("{"), 0644))
+		assert.Empty(t, getEventPayloads(t, NewRegistry(RegistryWithSnapshotStore(NewFileSnapshotStore(path)))))
+	})
+
+	t.Run("restored events get the config of their registered alert", func(t *testing.T) {
+		store := NewFileSnapshotStore(filepath.Join(t.TempDir(), "events.json"))
+		newDrainAlert := func() Alert {
+			return NewAlert("configReloaded", "configuration reloaded", connectorpb.Severity_SEVERITY_INFO, connectorpb.State_STATE_HEALTHY,
+				EventWithDeliveryMode(DeliveryDrain), EventWithEscalation(time.Minute))
+		}
+		reg := NewRegistry(RegistryWithSnapshotStore(store))
+		alert := newDrainAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+		require.NoError(t, alert.Publish())
+		reg.Close()
+
+		restored := NewRegistry(RegistryWithSnapshotStore(store))
+		defer restored.Close()
+		require.NoError(t, restored.RegisterAlert(newDrainAlert()))
+
+		restored.rwLock.RLock()
+		for _, entry := range restored.events {
+			assert.Equal(t, DeliveryDrain, entry.delivery)
+			assert.NotNil(t, entry.escalation)
+		}
+		restored.rwLock.RUnlock()
+		assert.Len(t, getEventPayloads(t, restored), 1)
+		assert.Empty(t, getEventPayloads(t, restored))
+	})
+
+	t.Run("snapshots are saved in the background without blocking publishers", func(t *testing.T) {
+		store := &blockingSnapshotStore{saving: make(chan struct{}), release: make(chan struct{})}
+		reg := NewRegistry(RegistryWithSnapshotStore(store))
+		alert := newAlert()
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish(AlertWithStreamID("stream0")))
+		<-store.saving
+		for i := 1; i < 10; i++ {
+			require.NoError(t, alert.Publish(AlertWithStreamID(fmt.Sprintf("stream%d", i))))
+		}
+		close(store.release)
+		reg.Close()
+
+		store.lock.Lock()
+		defer store.lock.Unlock()
+		assert.Equal(t, 2, store.saves)
+		assert.Len(t, store.snapshot.Events, 10)
+	})
+}
+
+// blockingSnapshotStore signals the first save and blocks it until released
+type blockingSnapshotStore struct {
+	saving   chan struct{}
+	release  chan struct{}
+	lock     sync.Mutex
+	saves    int
+	snapshot *Snapshot
+}
+
+func (s *blockingSnapshotStore) Save(snapshot *Snapshot) error {
+	s.lock.Lock()
+	s.saves++
+	first := s.saves == 1
+	s.lock.Unlock()
+	if first {
+		s.saving <- struct{}{}
+		<-s.release
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.snapshot = snapshot
+	return nil
+}
+
+func (s *blockingSnapshotStore) Load() (*Snapshot, error) {
+	return nil, nil
+}
@@ -3,8 +3,10 @@ package events
 
 import (
 	"fmt"
+	"time"
 
 	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 // StatusOpts defines the type for the functional options for publishing status
@@ -12,32 +14,45 @@ type StatusOpts func(*statusInst)
 
 // Status is the interface for updating status
 type Status interface {
+	Event
 	Publish(...StatusOpts) error
+	// Clear removes the published status. Only the stream ID option is taken into account.
+	Clear(...StatusOpts) error
 }
 
 type statusImpl struct {
-	name     string
-	id       string
-	message  string
-	state    connectorpb.State
-	registry *Registry
+	name    string
+	id      string
+	message messageTemplate
+	state   connectorpb.State
+	config  eventConfig
+	binding
 }
 
 var _ Status = (*statusImpl)(nil)
 
+func (s *statusImpl) publishConfig() eventConfig {
+	return s.config
+}
+
 // NewStatus creates a status object that can be registered with the registry
-func NewStatus(name string, message string, state connectorpb.State) Status {
+func NewStatus(name string, message string, state connectorpb.State, opts ...EventOpts) Status {
 	return &statusImpl{
 		name:    name,
-		message: message,
+		message: newMessageTemplate(name, message),
 		state:   state,
+		config:  newEventConfig(opts),
 	}
 }
 
 type statusInst struct {
-	status   *statusImpl
-	streamID string
-	metadata *EventMetadata
+	status      *statusImpl
+	streamID    string
+	metadata    *EventMetadata
+	ttl         time.Duration
+	messageArgs []interface{}
+	messageData interface{}
+	state       connectorpb.State
 }
 
 // StatusWithStreamID is for publishing a stream specific status
@@ -54,14 +69,45 @@ func StatusWithEventMetadata(metadata *EventMetadata) StatusOpts {
 	}
 }
 
+// StatusWithTTL is for publishing a status that expires unless it is published again within the ttl.
+// It overrides the default ttl of the registry.
+func StatusWithTTL(ttl time.Duration) StatusOpts {
+	return func(inst *statusInst) {
+		inst.ttl = ttl
+	}
+}
+
+// StatusWithMessageArgs is for publishing a status whose message is a printf-style format string
+func StatusWithMessageArgs(args ...interface{}) StatusOpts {
+	return func(inst *statusInst) {
+		inst.messageArgs = args
+	}
+}
+
+// StatusWithMessageData is for publishing a status whose message is a text/template rendered with the data
+func StatusWithMessageData(data interface{}) StatusOpts {
+	return func(inst *statusInst) {
+		inst.messageData = data
+	}
+}
+
+// StatusWithState is for publishing the status with a state other than the one it was created with
+func StatusWithState(state connectorpb.State) StatusOpts {
+	return func(inst *statusInst) {
+		inst.state = state
+	}
+}
+
 // Publish publishes the status with the provided options
 func (s *statusImpl) Publish(opts ...StatusOpts) error {
-	if s.registry == nil {
+	registry := s.registry()
+	if registry == nil {
 		return fmt.Errorf("status not registered with the registry")
 	}
 
 	inst := &statusInst{
 		status: s,
+		state:  s.state,
 	}
 	for _, opt := range opts {
 		opt(inst)
@@ -69,22 +115,56 @@ func (s *statusImpl) Publish(opts ...StatusOpts) error {
 	statusEvent := &connectorpb.Status{
 		Id:       s.name,
 		StreamId: inst.streamID,
-		Message:  s.message,
-		State:    s.state,
+		Message:  s.message.render(inst.messageArgs, inst.messageData),
+		State:    inst.state,
 	}
 
 	if inst.metadata != nil {
-		metadata, err := inst.metadata.toStruct()
-		if err != nil {
-			return err
-		}
-		statusEvent.Metadata = metadata
+		statusEvent.Metadata = inst.metadata.toStruct()
 	}
 
-	s.registry.addEvent(statusEvent)
+	if statusEvent.Message != s.message.String() {
+		statusEvent.Metadata = withMetadataFields(statusEvent.Metadata, map[string]*structpb.Value{
+			messageTemplateProp: structpb.NewStringValue(s.message.String()),
+		})
+	}
+
+	registry.addEvent(&publication{
+		event:  statusEvent,
+		ttl:    inst.ttl,
+		config: s.config,
+	})
+	return nil
+}
+
+// Clear removes the status published with the provided stream ID option
+func (s *statusImpl) Clear(opts ...StatusOpts) error {
+	registry := s.registry()
+	if registry == nil {
+		return fmt.Errorf("status not registered with the registry")
+	}
+
+	inst := &statusInst{
+		status: s,
+	}
+	for _, opt := range opts {
+		opt(inst)
+	}
+
+	registry.removeEvent(statusKey(s.name, inst.streamID))
 	return nil
 }
 
+// Name returns the name of the status
+func (s *statusImpl) Name() string {
+	return s.name
+}
+
+// Bind binds the status to the registry it is registered with
+func (s *statusImpl) Bind(registry *Registry) error {
+	return s.bind(registry)
+}
+
 // String creates a stringified representation of the status
 func (s *statusImpl) String() string {
 	return fmt.Sprintf("[name: %s][message: %s][state: %s]", s.name, s.message, s.state)
@@ -0,0 +1,169 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	suppressedHeld        = "held"
+	suppressedDuplicate   = "duplicate"
+	suppressedRateLimited = "rate_limited"
+)
+
+// EventOpts defines the type for the functional options for creating alerts and statuses
+type EventOpts func(*eventConfig)
+
+// eventConfig controls how the publications of an event are exposed by the registry
+type eventConfig struct {
+	holdTime      time.Duration
+	hysteresis    int
+	dedupWindow   time.Duration
+	rateLimit     float64
+	burst         int
+	delivery      *DeliveryMode
+	escalateAfter time.Duration
+}
+
+func newEventConfig(opts []EventOpts) eventConfig {
+	var cfg eventConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// EventWithMinHoldTime exposes a change of state or severity only after it has been published
+// for at least the hold time. A change that reverts within the hold time is suppressed as a flap.
+func EventWithMinHoldTime(holdTime time.Duration) EventOpts {
+	return func(cfg *eventConfig) {
+		cfg.holdTime = holdTime
+	}
+}
+
+// EventWithHysteresis exposes a change of state or severity only after it has been published
+// the given number of times in a row. A change that reverts before that is suppressed as a flap.
+func EventWithHysteresis(publications int) EventOpts {
+	return func(cfg *eventConfig) {
+		cfg.hysteresis = publications
+	}
+}
+
+// EventWithDeduplication suppresses republications of an unchanged event within the window
+func EventWithDeduplication(window time.Duration) EventOpts {
+	return func(cfg *eventConfig) {
+		cfg.dedupWindow = window
+	}
+}
+
+// EventWithRateLimit limits the republications of an event that does not change state or severity
+// to perSecond, with bursts of up to burst republications
+func EventWithRateLimit(perSecond float64, burst int) EventOpts {
+	return func(cfg *eventConfig) {
+		cfg.rateLimit = perSecond
+		cfg.burst = burst
+	}
+}
+
+// condition is the part of an event whose changes are subject to flap suppression
+type condition struct {
+	state    connectorpb.State
+	severity connectorpb.Severity
+}
+
+// pendingChange is a change of condition that has not been exposed yet
+type pendingChange struct {
+	condition    condition
+	since        time.Time
+	publications int
+}
+
+func eventCondition(event interface{}) condition {
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		return condition{state: event.State, severity: event.Severity}
+	case *connectorpb.Status:
+		return condition{state: event.State}
+	}
+	return condition{}
+}
+
+// admit decides whether a publication of an event that is already held by the registry is exposed.
+// The caller must hold the write lock.
+func (reg *Registry) admit(entry *eventEntry, pub *publication, now time.Time) bool {
+	cfg := pub.config
+	eventType, id := eventTypeName(pub.event), eventID(pub.event)
+
+	if next := eventCondition(pub.event); next != eventCondition(entry.event) {
+		if cfg.holdTime <= 0 && cfg.hysteresis <= 1 {
+			entry.pending = nil
+			return true
+		}
+		if entry.pending == nil || entry.pending.condition != next {
+			entry.pending = &pendingChange{condition: next, since: now}
+		}
+		entry.pending.publications++
+		if entry.pending.publications >= cfg.hysteresis && now.Sub(entry.pending.since) >= cfg.holdTime {
+			entry.pending = nil
+			return true
+		}
+		reg.metrics.suppressedPublishes.WithLabelValues(eventType, id, suppressedHeld).Inc()
+		return false
+	}
+
+	if entry.pending != nil {
+		entry.pending = nil
+		reg.metrics.suppressedFlaps.WithLabelValues(eventType, id).Inc()
+	}
+	if cfg.dedupWindow > 0 && now.Sub(entry.lastSeen) < cfg.dedupWindow && sameEvent(entry.event, pub.event) {
+		reg.metrics.suppressedPublishes.WithLabelValues(eventType, id, suppressedDuplicate).Inc()
+		return false
+	}
+	if entry.limiter != nil && !entry.limiter.AllowN(1) {
+		reg.metrics.suppressedPublishes.WithLabelValues(eventType, id, suppressedRateLimited).Inc()
+		return false
+	}
+	return true
+}
+
+// sameEvent reports whether the events are equal apart from the properties managed by the registry
+func sameEvent(a interface{}, b interface{}) bool {
+	ma, ok := a.(proto.Message)
+	if !ok {
+		return false
+	}
+	mb, ok := b.(proto.Message)
+	if !ok {
+		return false
+	}
+	return proto.Equal(withoutManagedFields(ma), withoutManagedFields(mb))
+}
+
+func withoutManagedFields(event proto.Message) proto.Message {
+	event = proto.Clone(event)
+	switch event := event.(type) {
+	case *connectorpb.Alert:
+		event.CreatedAt = nil
+		event.Metadata = withoutManagedMetadata(event.Metadata)
+	case *connectorpb.Status:
+		event.CreatedAt = nil
+		event.Metadata = withoutManagedMetadata(event.Metadata)
+	}
+	return event
+}
+
+func withoutManagedMetadata(metadata *structpb.Struct) *structpb.Struct {
+	if metadata == nil {
+		return nil
+	}
+	delete(metadata.Fields, lastSeenAtProp)
+	delete(metadata.Fields, occurrencesProp)
+	if len(metadata.Fields) == 0 {
+		return nil
+	}
+	return metadata
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSuppression(t *testing.T) {
+	currentState := func(t *testing.T, reg *Registry) connectorpb.State {
+		payloads := getEventPayloads(t, reg)
+		require.Len(t, payloads, 1)
+		return payloads[0].GetStatus().GetState()
+	}
+
+	t.Run("state changes are exposed after the hold time", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry(RegistryWithMetrics(prometheus.NewRegistry()))
+		reg.now = clock.now
+		status := NewStatus("dbHealth", "database health", connectorpb.State_STATE_HEALTHY, EventWithMinHoldTime(time.Minute))
+		require.NoError(t, reg.RegisterStatus(status))
+		unhealthy := StatusWithState(connectorpb.State_STATE_UNHEALTHY)
+
+		require.NoError(t, status.Publish())
+		require.NoError(t, status.Publish(unhealthy))
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, currentState(t, reg))
+
+		clock.advance(30 * time.Second)
+		require.NoError(t, status.Publish())
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, currentState(t, reg))
+		assert.Equal(t, float64(1), testutil.ToFloat64(reg.metrics.suppressedFlaps.WithLabelValues("status", "dbHealth")))
+
+		require.NoError(t, status.Publish(unhealthy))
+		clock.advance(time.Minute)
+		require.NoError(t, status.Publish(unhealthy))
+		assert.Equal(t, connectorpb.State_STATE_UNHEALTHY, currentState(t, reg))
+	})
+
+	t.Run("state changes are exposed after consecutive publications", func(t *testing.T) {
+		reg := NewRegistry()
+		status := NewStatus("dbHealth", "database health", connectorpb.State_STATE_HEALTHY, EventWithHysteresis(3))
+		require.NoError(t, reg.RegisterStatus(status))
+		unhealthy := StatusWithState(connectorpb.State_STATE_UNHEALTHY)
+
+		require.NoError(t, status.Publish())
+		require.NoError(t, status.Publish(unhealthy))
+		require.NoError(t, status.Publish(unhealthy))
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, currentState(t, reg))
+		require.NoError(t, status.Publish(unhealthy))
+		assert.Equal(t, connectorpb.State_STATE_UNHEALTHY, currentState(t, reg))
+	})
+
+	t.Run("unchanged republications are deduplicated within the window", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry()
+		reg.now = clock.now
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED, EventWithDeduplication(time.Minute))
+		require.NoError(t, reg.RegisterAlert(alert))
+		metadata := &EventMetadata{ErrorMessage: "timeout"}
+
+		require.NoError(t, alert.Publish(AlertWithEventMetadata(metadata)))
+		clock.advance(time.Second)
+		require.NoError(t, alert.Publish(AlertWithEventMetadata(metadata)))
+		assert.Len(t, reg.AlertHistory("unableToFetchData", ""), 1)
+
+		require.NoError(t, alert.Publish(AlertWithEventMetadata(&EventMetadata{ErrorMessage: "refused"})))
+		assert.Len(t, reg.AlertHistory("unableToFetchData", ""), 2)
+
+		clock.advance(time.Minute)
+		require.NoError(t, alert.Publish(AlertWithEventMetadata(&EventMetadata{ErrorMessage: "refused"})))
+		assert.Len(t, reg.AlertHistory("unableToFetchData", ""), 3)
+	})
+
+	t.Run("republications are rate limited", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		reg := NewRegistry()
+		reg.now = clock.now
+		alert := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED, EventWithRateLimit(0.1, 2))
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, alert.Publish())
+		}
+		assert.Len(t, reg.AlertHistory("unableToFetchData", ""), 3)
+		assert.Equal(t, float64(2), testutil.ToFloat64(reg.metrics.suppressedPublishes.WithLabelValues("alert", "unableToFetchData", suppressedRateLimited)))
+
+		clock.advance(10 * time.Second)
+		require.NoError(t, alert.Publish())
+		assert.Len(t, reg.AlertHistory("unableToFetchData", ""), 4)
+	})
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/golang/glog"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+)
+
+const defaultWatchBufferSize = 64
+
+// WatchOpts defines the type for the functional options for watching a registry
+type WatchOpts func(*watcher)
+
+// WatchWithAlerts limits the changes to the ones of alerts, unless WatchWithStatuses is set as well
+func WatchWithAlerts() WatchOpts {
+	return func(w *watcher) {
+		w.alerts = true
+	}
+}
+
+// WatchWithStatuses limits the changes to the ones of statuses, unless WatchWithAlerts is set as well
+func WatchWithStatuses() WatchOpts {
+	return func(w *watcher) {
+		w.statuses = true
+	}
+}
+
+// WatchWithChangeTypes limits the changes to the given types
+func WatchWithChangeTypes(types ...ChangeType) WatchOpts {
+	return func(w *watcher) {
+		w.changeTypes = append(w.changeTypes, types...)
+	}
+}
+
+// WatchWithSeverities limits the changes to the ones of alerts with the given severities
+func WatchWithSeverities(severities ...connectorpb.Severity) WatchOpts {
+	return func(w *watcher) {
+		w.severities = append(w.severities, severities...)
+	}
+}
+
+// WatchWithStreamIDs limits the changes to the ones of events of the given streams. The empty
+// stream ID matches connector scoped events.
+func WatchWithStreamIDs(streamIDs ...string) WatchOpts {
+	return func(w *watcher) {
+		w.streamIDs = append(w.streamIDs, streamIDs...)
+	}
+}
+
+// WatchWithBufferSize sets the number of changes that can wait to be received before new ones are dropped
+func WatchWithBufferSize(size int) WatchOpts {
+	return func(w *watcher) {
+		w.bufferSize = size
+	}
+}
+
+// watcher is a sink forwarding the matching changes to a channel
+type watcher struct {
+	alerts      bool
+	statuses    bool
+	changeTypes []ChangeType
+	severities  []connectorpb.Severity
+	streamIDs   []string
+	bufferSize  int
+	ch          chan EventChange
+	closed      bool
+	lock        sync.RWMutex
+}
+
+// Watch returns a channel receiving the changes of the events in the registry that match the options, until
+// the context is done. Changes are sent without blocking publishers: when the buffer of a watcher that does not
+// keep up is full, new changes are dropped for that watcher.
+func (reg *Registry) Watch(ctx context.Context, opts ...WatchOpts) <-chan EventChange {
+	w := &watcher{
+		bufferSize: defaultWatchBufferSize,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.ch = make(chan EventChange, w.bufferSize)
+
+	reg.AddSink(w)
+	go func() {
+		<-ctx.Done()
+		reg.removeWatcher(w)
+		w.close()
+	}()
+	return w.ch
+}
+
+// Notify sends the change to the channel if it matches the filters of the watcher
+func (w *watcher) Notify(change EventChange) {
+	if !w.matches(change) {
+		return
+	}
+
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.ch <- change:
+	default:
+		glog.Warningf("dropping %s change of %s %s for a slow watcher", change.Type, eventTypeName(change.Event), eventID(change.Event))
+	}
+}
+
+func (w *watcher) close() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.closed = true
+	close(w.ch)
+}
+
+func (w *watcher) matches(change EventChange) bool {
+	alert := change.Alert()
+	if w.alerts != w.statuses && (alert != nil) != w.alerts {
+		return false
+	}
+	if len(w.changeTypes) > 0 && !containsChangeType(w.changeTypes, change.Type) {
+		return false
+	}
+	if len(w.severities) > 0 && (alert == nil || !containsSeverity(w.severities, alert.Severity)) {
+		return false
+	}
+	if len(w.streamIDs) > 0 && !containsString(w.streamIDs, eventStreamID(change.Event)) {
+		return false
+	}
+	return true
+}
+
+// removeWatcher removes the watcher from the sinks notified of changes
+func (reg *Registry) removeWatcher(w *watcher) {
+	reg.rwLock.Lock()
+	defer reg.rwLock.Unlock()
+
+	sinks := make([]EventSink, 0, len(reg.sinks))
+	for _, sink := range reg.sinks {
+		if other, ok := sink.(*watcher); ok && other == w {
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+	reg.sinks = sinks
+}
+
+func containsChangeType(types []ChangeType, t ChangeType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSeverity(severities []connectorpb.Severity, severity connectorpb.Severity) bool {
+	for _, candidate := range severities {
+		if candidate == severity {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
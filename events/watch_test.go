@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	newRegistry := func(t *testing.T) (*Registry, Alert, Alert, Status) {
+		reg := NewRegistry()
+		critical := NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		warning := NewAlert("slowResponses", "slow responses", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_HEALTHY)
+		status := NewStatus("unableToContactDB", "unable to contact the database", connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterAlert(critical))
+		require.NoError(t, reg.RegisterAlert(warning))
+		require.NoError(t, reg.RegisterStatus(status))
+		return reg, critical, warning, status
+	}
+	receive := func(t *testing.T, ch <-chan EventChange) EventChange {
+		select {
+		case change := <-ch:
+			return change
+		case <-time.After(time.Second):
+			require.FailNow(t, "no change received")
+		}
+		return EventChange{}
+	}
+
+	t.Run("watchers receive the changes matching their filters", func(t *testing.T) {
+		reg, critical, warning, status := newRegistry(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		all := reg.Watch(ctx)
+		criticalAlerts := reg.Watch(ctx, WatchWithSeverities(connectorpb.Severity_SEVERITY_CRITICAL))
+		stream1Statuses := reg.Watch(ctx, WatchWithStatuses(), WatchWithStreamIDs("stream1"))
+		resolved := reg.Watch(ctx, WatchWithAlerts(), WatchWithChangeTypes(EventResolved))
+
+		require.NoError(t, warning.Publish())
+		require.NoError(t, status.Publish())
+		require.NoError(t, status.Publish(StatusWithStreamID("stream1")))
+		require.NoError(t, critical.Publish(AlertWithStreamID("stream1")))
+		require.NoError(t, critical.Resolve(AlertWithStreamID("stream1")))
+
+		assert.Len(t, all, 5)
+		change := receive(t, criticalAlerts)
+		assert.Equal(t, EventPublished, change.Type)
+		assert.Equal(t, "unableToFetchData", change.Alert().GetId())
+		assert.Equal(t, EventResolved, receive(t, criticalAlerts).Type)
+		assert.Equal(t, "stream1", receive(t, stream1Statuses).Status().GetStreamId())
+		assert.Empty(t, stream1Statuses)
+		assert.Equal(t, "unableToFetchData", receive(t, resolved).Alert().GetId())
+		assert.Empty(t, resolved)
+	})
+
+	t.Run("slow watchers do not block publishers", func(t *testing.T) {
+		reg, critical, _, _ := newRegistry(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		ch := reg.Watch(ctx, WatchWithBufferSize(2))
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, critical.Publish())
+		}
+		assert.Len(t, ch, 2)
+	})
+
+	t.Run("the channel is closed when the context is done", func(t *testing.T) {
+		reg, critical, _, _ := newRegistry(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		ch := reg.Watch(ctx)
+		cancel()
+
+		for range ch {
+		}
+		require.NoError(t, critical.Publish())
+		reg.rwLock.RLock()
+		defer reg.rwLock.RUnlock()
+		assert.Empty(t, reg.sinks)
+	})
+}
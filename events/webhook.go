@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const (
+	defaultWebhookQueueSize = 100
+	defaultWebhookTimeout   = 10 * time.Second
+)
+
+// WebhookSinkOpts defines the type for the functional options for creating a webhook sink
+type WebhookSinkOpts func(*WebhookSink)
+
+// WebhookSinkWithClient sets the HTTP client used for posting changes
+func WebhookSinkWithClient(client *http.Client) WebhookSinkOpts {
+	return func(s *WebhookSink) {
+		s.client = client
+	}
+}
+
+// WebhookSinkWithHeader sets a header on every request, e.g. for authorization
+func WebhookSinkWithHeader(key string, value string) WebhookSinkOpts {
+	return func(s *WebhookSink) {
+		s.headers.Set(key, value)
+	}
+}
+
+// WebhookSinkWithQueueSize sets the number of changes that can wait to be posted before new ones are dropped
+func WebhookSinkWithQueueSize(size int) WebhookSinkOpts {
+	return func(s *WebhookSink) {
+		s.queueSize = size
+	}
+}
+
+// WebhookSink posts every change as JSON to an HTTP endpoint. Changes are posted in order from
+// a background goroutine, so a slow endpoint does not block publishing events.
+type WebhookSink struct {
+	url       string
+	client    *http.Client
+	headers   http.Header
+	queueSize int
+	queue     chan EventChange
+	done      chan struct{}
+	closed    bool
+	lock      sync.RWMutex
+}
+
+var _ EventSink = (*WebhookSink)(nil)
+
+// NewWebhookSink creates a sink that posts changes to the url until it is closed
+func NewWebhookSink(url string, opts ...WebhookSinkOpts) *WebhookSink {
+	s := &WebhookSink{
+		url:       url,
+		client:    &http.Client{Timeout: defaultWebhookTimeout},
+		headers:   make(http.Header),
+		queueSize: defaultWebhookQueueSize,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.queue = make(chan EventChange, s.queueSize)
+
+	go s.run()
+	return s
+}
+
+// Notify queues the change to be posted, dropping it if the queue is full or the sink is closed
+func (s *WebhookSink) Notify(change EventChange) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.queue <- change:
+	default:
+		glog.Warningf("dropping %s event change for webhook %s, queue is full", change.Type, s.url)
+	}
+}
+
+// Close stops accepting changes and waits for the queued changes to be posted
+func (s *WebhookSink) Close() {
+	s.lock.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.lock.Unlock()
+	<-s.done
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	for change := range s.queue {
+		if err := s.post(change); err != nil {
+			glog.Errorf("unable to post %s event change to webhook %s: %s", change.Type, s.url, err.Error())
+		}
+	}
+}
+
+func (s *WebhookSink) post(change EventChange) error {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range s.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return nil
+}
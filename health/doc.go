@@ -0,0 +1,30 @@
+// Copyright (c) 2021 Nutanix, Inc.
+/*
+Package health aggregates the statuses held by an events.Registry into an overall health of the connector and serves
+it through the standard `grpc.health.v1` service and the `/healthz` and `/readyz` HTTP endpoints. This allows the
+liveness and readiness probes of the connector to reflect what `GetEvents` reports.
+
+A checker is created from a registry and a set of rules. Each rule derives a health level from the current statuses,
+and the worst level of all rules is the health of the connector e.g.
+	checker := NewChecker(registry,
+		CheckerWithRules(
+			AnyStreamStatusIn(Degraded, connector.State_STATE_UNHEALTHY),
+			AnyConnectorStatusIn(Unhealthy, connector.State_STATE_FAILED),
+		),
+	)
+	level := checker.Check()
+
+Without rules, the checker uses DefaultRules.
+
+The checker reports the connector as not ready once the health reaches the readiness threshold, Degraded by default,
+and as not live once it reaches the liveness threshold, Unhealthy by default. The thresholds can be changed with
+CheckerWithReadinessThreshold and CheckerWithLivenessThreshold.
+
+Serving the health through the grpc server of the connector requires registering the checker with it. The grpc health
+service reports SERVING as long as the connector is ready:
+	checker.RegisterGRPC(grpcServer)
+
+Serving the health through HTTP requires registering the handlers with a mux:
+	checker.RegisterHTTP(mux)
+*/
+package health
@@ -0,0 +1,269 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// LivenessPath is the HTTP path of the liveness endpoint
+	LivenessPath = "/healthz"
+	// ReadinessPath is the HTTP path of the readiness endpoint
+	ReadinessPath = "/readyz"
+
+	defaultWatchInterval = 5 * time.Second
+)
+
+// Level is the aggregated health of a connector
+type Level int
+
+const (
+	// Healthy means that no rule found a problem
+	Healthy Level = iota
+	// Degraded means that the connector is working with reduced functionality
+	Degraded
+	// Unhealthy means that the connector is not working
+	Unhealthy
+)
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Unhealthy:
+		return "unhealthy"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Rule derives a health level from the statuses currently held by a registry
+type Rule func(statuses []*connectorpb.Status) Level
+
+// AnyStatusIn reports the level if any status is in one of the states
+func AnyStatusIn(level Level, states ...connectorpb.State) Rule {
+	return statusRule(level, states, func(*connectorpb.Status) bool { return true })
+}
+
+// AnyStreamStatusIn reports the level if any stream scoped status is in one of the states
+func AnyStreamStatusIn(level Level, states ...connectorpb.State) Rule {
+	return statusRule(level, states, func(s *connectorpb.Status) bool { return s.GetStreamId() != "" })
+}
+
+// AnyConnectorStatusIn reports the level if any connector scoped status is in one of the states
+func AnyConnectorStatusIn(level Level, states ...connectorpb.State) Rule {
+	return statusRule(level, states, func(s *connectorpb.Status) bool { return s.GetStreamId() == "" })
+}
+
+// StatusIn reports the level if the status with the name is in one of the states for any stream
+func StatusIn(name string, level Level, states ...connectorpb.State) Rule {
+	return statusRule(level, states, func(s *connectorpb.Status) bool { return s.GetId() == name })
+}
+
+func statusRule(level Level, states []connectorpb.State, match func(*connectorpb.Status) bool) Rule {
+	return func(statuses []*connectorpb.Status) Level {
+		for _, s := range statuses {
+			if !match(s) {
+				continue
+			}
+			for _, state := range states {
+				if s.GetState() == state {
+					return level
+				}
+			}
+		}
+		return Healthy
+	}
+}
+
+// DefaultRules report a failed or unhealthy connector scoped status as Unhealthy,
+// and a failed or unhealthy stream scoped status as Degraded
+func DefaultRules() []Rule {
+	return []Rule{
+		AnyConnectorStatusIn(Unhealthy, connectorpb.State_STATE_FAILED, connectorpb.State_STATE_UNHEALTHY),
+		AnyStreamStatusIn(Degraded, connectorpb.State_STATE_FAILED, connectorpb.State_STATE_UNHEALTHY),
+	}
+}
+
+// CheckerOpts defines the type for the functional options for creating a checker
+type CheckerOpts func(*Checker)
+
+// CheckerWithRules sets the rules the health is derived from
+func CheckerWithRules(rules ...Rule) CheckerOpts {
+	return func(c *Checker) {
+		c.rules = rules
+	}
+}
+
+// CheckerWithReadinessThreshold sets the level from which the connector is reported as not ready
+func CheckerWithReadinessThreshold(level Level) CheckerOpts {
+	return func(c *Checker) {
+		c.readinessThreshold = level
+	}
+}
+
+// CheckerWithLivenessThreshold sets the level from which the connector is reported as not live
+func CheckerWithLivenessThreshold(level Level) CheckerOpts {
+	return func(c *Checker) {
+		c.livenessThreshold = level
+	}
+}
+
+// CheckerWithWatchInterval sets how often the health is checked for streaming it to grpc health watchers.
+// Non-positive intervals are ignored.
+func CheckerWithWatchInterval(interval time.Duration) CheckerOpts {
+	return func(c *Checker) {
+		if interval > 0 {
+			c.watchInterval = interval
+		}
+	}
+}
+
+// Checker aggregates the statuses held by a registry into the health of the connector
+type Checker struct {
+	registry           *events.Registry
+	rules              []Rule
+	readinessThreshold Level
+	livenessThreshold  Level
+	watchInterval      time.Duration
+}
+
+// NewChecker creates a checker for the statuses held by the registry
+func NewChecker(registry *events.Registry, opts ...CheckerOpts) *Checker {
+	c := &Checker{
+		registry:           registry,
+		rules:              DefaultRules(),
+		readinessThreshold: Degraded,
+		livenessThreshold:  Unhealthy,
+		watchInterval:      defaultWatchInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Check returns the worst level reported by the rules for the current statuses
+func (c *Checker) Check() Level {
+	statuses := c.registry.Statuses()
+	level := Healthy
+	for _, rule := range c.rules {
+		if l := rule(statuses); l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// Ready reports whether the health is below the readiness threshold
+func (c *Checker) Ready() bool {
+	return c.Check() < c.readinessThreshold
+}
+
+// Live reports whether the health is below the liveness threshold
+func (c *Checker) Live() bool {
+	return c.Check() < c.livenessThreshold
+}
+
+// LivenessHandler returns an HTTP handler that responds with 200 while the connector is live and 503 otherwise
+func (c *Checker) LivenessHandler() http.Handler {
+	return c.handler(c.livenessThreshold)
+}
+
+// ReadinessHandler returns an HTTP handler that responds with 200 while the connector is ready and 503 otherwise
+func (c *Checker) ReadinessHandler() http.Handler {
+	return c.handler(c.readinessThreshold)
+}
+
+// RegisterHTTP registers the liveness and readiness handlers with the mux
+func (c *Checker) RegisterHTTP(mux *http.ServeMux) {
+	mux.Handle(LivenessPath, c.LivenessHandler())
+	mux.Handle(ReadinessPath, c.ReadinessHandler())
+}
+
+func (c *Checker) handler(threshold Level) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		level := c.Check()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if level >= threshold {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = fmt.Fprintln(w, level)
+	})
+}
+
+// RegisterGRPC registers the checker as the grpc.health.v1 service of the grpc server
+func (c *Checker) RegisterGRPC(server grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(server, &grpcHealthServer{checker: c})
+}
+
+// grpcHealthServer serves the readiness of the connector for the overall server
+// and the connector service
+type grpcHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	checker *Checker
+}
+
+var _ healthpb.HealthServer = (*grpcHealthServer)(nil)
+
+// Check returns the serving status of the service
+func (s *grpcHealthServer) Check(_ context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !knownService(req.GetService()) {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: s.servingStatus()}, nil
+}
+
+// Watch streams the serving status of the service whenever it changes. An unknown service is reported
+// as SERVICE_UNKNOWN and the stream is kept open, as required by the grpc.health.v1 protocol.
+func (s *grpcHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if !knownService(req.GetService()) {
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN}); err != nil {
+			return err
+		}
+		<-stream.Context().Done()
+		return status.Error(codes.Canceled, "stream has ended")
+	}
+
+	ticker := time.NewTicker(s.checker.watchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		if current := s.servingStatus(); current != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: current}); err != nil {
+				return err
+			}
+			last = current
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *grpcHealthServer) servingStatus() healthpb.HealthCheckResponse_ServingStatus {
+	if s.checker.Ready() {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+func knownService(service string) bool {
+	return service == "" || service == connectorpb.ConnectorService_ServiceDesc.ServiceName
+}
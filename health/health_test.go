@@ -0,0 +1,150 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package health
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestChecker(t *testing.T) {
+	newRegistry := func(t *testing.T) (*events.Registry, events.Status) {
+		reg := events.NewRegistry()
+		dbStatus := events.NewStatus("dbHealth", "database health", connectorpb.State_STATE_HEALTHY)
+		require.NoError(t, reg.RegisterStatus(dbStatus))
+		return reg, dbStatus
+	}
+	unhealthy := events.StatusWithState(connectorpb.State_STATE_UNHEALTHY)
+
+	t.Run("default rules distinguish connector and stream statuses", func(t *testing.T) {
+		reg, dbStatus := newRegistry(t)
+		checker := NewChecker(reg)
+		assert.Equal(t, Healthy, checker.Check())
+
+		require.NoError(t, dbStatus.Publish())
+		require.NoError(t, dbStatus.Publish(unhealthy, events.StatusWithStreamID("stream1")))
+		assert.Equal(t, Degraded, checker.Check())
+		assert.False(t, checker.Ready())
+		assert.True(t, checker.Live())
+
+		require.NoError(t, dbStatus.Publish(unhealthy))
+		assert.Equal(t, Unhealthy, checker.Check())
+		assert.False(t, checker.Live())
+	})
+
+	t.Run("the worst level of the custom rules is reported", func(t *testing.T) {
+		reg, dbStatus := newRegistry(t)
+		checker := NewChecker(reg,
+			CheckerWithRules(
+				StatusIn("dbHealth", Degraded, connectorpb.State_STATE_UNHEALTHY),
+				AnyStatusIn(Unhealthy, connectorpb.State_STATE_FAILED),
+			),
+			CheckerWithReadinessThreshold(Unhealthy),
+		)
+
+		require.NoError(t, dbStatus.Publish(unhealthy))
+		assert.Equal(t, Degraded, checker.Check())
+		assert.True(t, checker.Ready())
+
+		require.NoError(t, dbStatus.Publish(events.StatusWithState(connectorpb.State_STATE_FAILED), events.StatusWithStreamID("stream1")))
+		assert.Equal(t, Unhealthy, checker.Check())
+		assert.False(t, checker.Ready())
+	})
+
+	t.Run("http probes respond with 503 past their threshold", func(t *testing.T) {
+		reg, dbStatus := newRegistry(t)
+		mux := http.NewServeMux()
+		NewChecker(reg).RegisterHTTP(mux)
+		probe := func(path string) int {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			return rec.Code
+		}
+
+		assert.Equal(t, http.StatusOK, probe(LivenessPath))
+		assert.Equal(t, http.StatusOK, probe(ReadinessPath))
+
+		require.NoError(t, dbStatus.Publish(unhealthy, events.StatusWithStreamID("stream1")))
+		assert.Equal(t, http.StatusOK, probe(LivenessPath))
+		assert.Equal(t, http.StatusServiceUnavailable, probe(ReadinessPath))
+	})
+
+	t.Run("grpc health check reports readiness of known services", func(t *testing.T) {
+		reg, dbStatus := newRegistry(t)
+		server := &grpcHealthServer{checker: NewChecker(reg)}
+		check := func(service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+			resp, err := server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+			return resp.GetStatus(), err
+		}
+
+		serving, err := check("")
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, serving)
+
+		require.NoError(t, dbStatus.Publish(unhealthy))
+		serving, err = check(connectorpb.ConnectorService_ServiceDesc.ServiceName)
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, serving)
+
+		_, err = check("unknown")
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("grpc health watch streams changes and keeps the stream of unknown services open", func(t *testing.T) {
+		reg, dbStatus := newRegistry(t)
+		assert.Positive(t, int64(NewChecker(reg, CheckerWithWatchInterval(0)).watchInterval))
+		server := &grpcHealthServer{checker: NewChecker(reg, CheckerWithWatchInterval(time.Millisecond))}
+		watch := func(service string) (*fakeWatchStream, context.CancelFunc, chan error) {
+			ctx, cancel := context.WithCancel(context.Background())
+			stream := &fakeWatchStream{ctx: ctx, sent: make(chan healthpb.HealthCheckResponse_ServingStatus, 10)}
+			done := make(chan error, 1)
+			go func() { done <- server.Watch(&healthpb.HealthCheckRequest{Service: service}, stream) }()
+			return stream, cancel, done
+		}
+
+		stream, cancel, done := watch("unknown")
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVICE_UNKNOWN, <-stream.sent)
+		select {
+		case <-done:
+			t.Fatal("watch of an unknown service returned before the stream ended")
+		case <-time.After(20 * time.Millisecond):
+		}
+		cancel()
+		assert.Equal(t, codes.Canceled, status.Code(<-done))
+
+		stream, cancel, done = watch("")
+		defer cancel()
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, <-stream.sent)
+		require.NoError(t, dbStatus.Publish(unhealthy))
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, <-stream.sent)
+		cancel()
+		<-done
+	})
+}
+
+// fakeWatchStream records the statuses sent to a grpc health watcher
+type fakeWatchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (s *fakeWatchStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeWatchStream) Send(resp *healthpb.HealthCheckResponse) error {
+	s.sent <- resp.GetStatus()
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is a rate limiter that refills at a fixed rate up to a burst size.
+// It is safe for concurrent use.
+type TokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	m      sync.Mutex
+}
+
+// NewTokenBucket creates a full token bucket that refills rate tokens per second up to burst tokens
+func NewTokenBucket(rate float64, burst float64) *TokenBucket {
+	return NewTokenBucketWithClock(rate, burst, time.Now)
+}
+
+// NewTokenBucketWithClock creates a full token bucket that reads the current time from now
+func NewTokenBucketWithClock(rate float64, burst float64, now func() time.Time) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now(),
+		now:    now,
+	}
+}
+
+// AllowN takes n tokens from the bucket if they are available and reports whether it did.
+// A request larger than the burst size is allowed when the bucket is full, leaving the
+// bucket in debt until it has refilled.
+func (tb *TokenBucket) AllowN(n float64) bool {
+	tb.m.Lock()
+	defer tb.m.Unlock()
+
+	tb.refill()
+	if tb.tokens < n && tb.tokens < tb.burst {
+		return false
+	}
+	tb.tokens -= n
+	return true
+}
+
+// ReturnN puts back n tokens taken from the bucket, up to the burst size
+func (tb *TokenBucket) ReturnN(n float64) {
+	tb.m.Lock()
+	defer tb.m.Unlock()
+
+	tb.refill()
+	tb.tokens += n
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+}
+
+// ReserveN takes n tokens from the bucket, going into debt if needed, and returns
+// how long the caller has to wait before the tokens are actually available
+func (tb *TokenBucket) ReserveN(n float64) time.Duration {
+	tb.m.Lock()
+	defer tb.m.Unlock()
+
+	tb.refill()
+	tb.tokens -= n
+	if tb.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+func (tb *TokenBucket) refill() {
+	now := tb.now()
+	elapsed := now.Sub(tb.last).Seconds()
+	tb.last = now
+	if elapsed <= 0 {
+		return
+	}
+	tb.tokens += elapsed * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func TestTokenBucket(t *testing.T) {
+	t.Run("AllowN allows up to burst and refills over time", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		tb := NewTokenBucketWithClock(2, 4, clock.now)
+		for i := 0; i < 4; i++ {
+			assert.True(t, tb.AllowN(1))
+		}
+		assert.False(t, tb.AllowN(1))
+
+		clock.t = clock.t.Add(500 * time.Millisecond)
+		assert.True(t, tb.AllowN(1))
+		assert.False(t, tb.AllowN(1))
+
+		clock.t = clock.t.Add(time.Hour)
+		for i := 0; i < 4; i++ {
+			assert.True(t, tb.AllowN(1))
+		}
+		assert.False(t, tb.AllowN(1))
+	})
+
+	t.Run("AllowN allows requests larger than burst on a full bucket", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		tb := NewTokenBucketWithClock(10, 10, clock.now)
+		assert.True(t, tb.AllowN(25))
+		assert.False(t, tb.AllowN(1))
+
+		clock.t = clock.t.Add(time.Second)
+		assert.False(t, tb.AllowN(1))
+		clock.t = clock.t.Add(2 * time.Second)
+		assert.True(t, tb.AllowN(1))
+	})
+
+	t.Run("ReserveN returns the time to wait for the tokens", func(t *testing.T) {
+		clock := &fakeClock{t: time.Unix(0, 0)}
+		tb := NewTokenBucketWithClock(10, 10, clock.now)
+		assert.Equal(t, time.Duration(0), tb.ReserveN(10))
+		assert.Equal(t, 500*time.Millisecond, tb.ReserveN(5))
+		assert.Equal(t, time.Second, tb.ReserveN(5))
+	})
+}
@@ -37,6 +37,56 @@ This callback gets called with a message as parameter each time a new message is
 Subsequently, a subscription can be created for subscribing to the data from the transport channel:
 	sub, err := client.Subscribe(stream.GetTransportChannel(), msgHandler)
 
+A single transport message can carry multiple payloads. `Subscribe` calls the callback once per payload, whereas
+`SubscribeBatch` calls a callback with the BatchHandler signature once per transport message. This preserves the
+batch boundary, e.g. for doing one multi-row insert per batch in an egress stream. Clients that do not implement the
+optional BatchSubscriber interface deliver batches of a single message:
+	func batchHandler (batch *Batch) {
+		// batch.Timestamp is the publish time, batch.Messages holds the payloads
+	}
+	sub, err := SubscribeBatch(client, stream.GetTransportChannel(), batchHandler)
+
+Messages can be handled in parallel with `SubscribeOrdered`. It shards messages across a number of workers by the
+key returned from a KeyFunc, so messages with the same key, e.g. from the same device, are still handled in order:
+	keyFn := func(msg *Message) string {
+		return deviceID(msg.Payload)
+	}
+	sub, err := SubscribeOrdered(client, stream.GetTransportChannel(), 8, keyFn, msgHandler)
+The number of messages waiting on each worker is exported as the `transport_ordered_shard_queue_depth` metric.
+
+A `StreamHandle` binds the client to a single stream and enforces its direction: an INGRESS stream can only publish
+to its transport channel and an EGRESS stream can only subscribe to it. Anything else fails with ErrWrongDirection.
+Metrics and events published through the handle are tagged with the stream ID:
+	h, err := NewStreamHandle(client, stream)
+	err = h.Publish(msg)
+	err = h.PublishAlert(unableToFetchDataAlert)
+
+Cross-cutting behavior such as filtering, enrichment, validation or auditing can be added to every message with
+middlewares. `WithMiddleware` wraps a client so that each published message passes through the PublishMiddleware and
+each received message passes through the SubscribeMiddleware of the given middlewares, in order. A middleware can
+short-circuit the chain by not calling the next function. The SDK ships `LoggingMiddleware`, `MaxPayloadSizeMiddleware`
+and `FilterMiddleware`:
+	client = WithMiddleware(client, LoggingMiddleware(), MaxPayloadSizeMiddleware(1 << 20))
+
+Publishing onto the shared transport broker can be limited per channel with `RateLimitMiddleware`. Each channel gets
+token buckets for messages and payload bytes per second. Publishes over the limit either block until they fit or
+fail with ErrRateLimited. Throttled publishes are counted in the `transport_throttled_messages` metric, and an alert
+can be raised when a channel stays throttled, which is resolved once publishes pass again:
+	limit := RateLimit{MessagesPerSecond: 100, BytesPerSecond: 1 << 20, Mode: RateLimitReject}
+	client = WithMiddleware(client, RateLimitMiddleware(limit, RateLimitWithAlert(throttledAlert, time.Minute)))
+
+The liveness of streams can be derived from their traffic with a `StalenessMonitor`. Its middleware records the
+messages published and received on each channel, and a watched stream without messages for the window gets its status
+published as UNHEALTHY and the stalled alert raised. Both recover once messages flow again:
+	monitor, err := NewStalenessMonitor(streamActivityStatus, stalledStreamAlert, 5*time.Minute)
+	defer monitor.Close()
+	client = WithMiddleware(client, monitor.Middleware())
+	h, err := NewStreamHandle(client, stream)
+	err = monitor.Watch(h)
+
+Changes of the events in an events.Registry can be published onto a channel as JSON with `NewEventSink`:
+	registry.AddSink(NewEventSink(client, "connector-events"))
+
 A subscription also exposes the channel it is subscribed to via the `Channel` method:
 	channel := sub.Channel()
 
@@ -0,0 +1,38 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"encoding/json"
+
+	"github.com/golang/glog"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+)
+
+type eventSink struct {
+	client  Client
+	channel string
+}
+
+var _ events.EventSink = (*eventSink)(nil)
+
+// NewEventSink creates an events sink that publishes every change of the events in a registry
+// as JSON onto the channel
+func NewEventSink(client Client, channel string) events.EventSink {
+	return &eventSink{
+		client:  client,
+		channel: channel,
+	}
+}
+
+// Notify publishes the change onto the channel
+func (s *eventSink) Notify(change events.EventChange) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		glog.Errorf("unable to marshal %s event change: %s", change.Type, err.Error())
+		return
+	}
+	err = s.client.Publish(s.channel, Message{Payload: data})
+	if err != nil {
+		glog.Errorf("unable to publish %s event change to %s: %s", change.Type, s.channel, err.Error())
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventSink(t *testing.T) {
+	t.Run("event changes are published onto the channel", func(t *testing.T) {
+		client := newFakeClient()
+		registry := events.NewRegistry(events.RegistryWithSinks(NewEventSink(client, "events")))
+		alert := events.NewAlert("unableToFetchData", "unable to fetch data", connectorpb.Severity_SEVERITY_CRITICAL, connectorpb.State_STATE_FAILED)
+		require.NoError(t, registry.RegisterAlert(alert))
+
+		require.NoError(t, alert.Publish(events.AlertWithStreamID("stream1")))
+		require.NoError(t, alert.Resolve(events.AlertWithStreamID("stream1")))
+
+		published := client.published["events"]
+		require.Len(t, published, 2)
+		var change struct {
+			Change string `json:"change"`
+			Event  struct {
+				Alert struct {
+					ID       string `json:"id"`
+					StreamID string `json:"streamId"`
+				} `json:"alert"`
+			} `json:"event"`
+		}
+		require.NoError(t, json.Unmarshal(published[0].Payload, &change))
+		assert.Equal(t, "published", change.Change)
+		assert.Equal(t, "unableToFetchData", change.Event.Alert.ID)
+		assert.Equal(t, "stream1", change.Event.Alert.StreamID)
+		require.NoError(t, json.Unmarshal(published[1].Payload, &change))
+		assert.Equal(t, "resolved", change.Change)
+	})
+}
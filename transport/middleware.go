@@ -0,0 +1,173 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+// ErrPayloadTooLarge is returned when a message exceeds the size allowed by MaxPayloadSizeMiddleware
+var ErrPayloadTooLarge = errors.New("payload too large")
+
+// PublishFunc defines the function signature of publishing a message onto a channel
+type PublishFunc func(channel string, msg Message) error
+
+// PublishMiddleware wraps the next PublishFunc in the chain. A middleware can short-circuit the
+// chain by returning without calling next.
+type PublishMiddleware func(next PublishFunc) PublishFunc
+
+// SubscribeMiddleware wraps the next MessageHandler in the chain for a subscription on the channel.
+// A middleware can short-circuit the chain by returning without calling next. For ordered subscriptions,
+// the handler runs before the message is queued on its worker.
+type SubscribeMiddleware func(channel string, next MessageHandler) MessageHandler
+
+// Middleware adds behavior to the publish path, the subscribe path or both. Either field can be nil.
+type Middleware struct {
+	Publish   PublishMiddleware
+	Subscribe SubscribeMiddleware
+}
+
+type middlewareClient struct {
+	client      Client
+	publish     PublishFunc
+	middlewares []Middleware
+}
+
+var _ Client = (*middlewareClient)(nil)
+
+// WithMiddleware returns a client that passes every published and every received message through
+// the middlewares. The first middleware is the outermost one, i.e. it sees each message first.
+func WithMiddleware(client Client, middlewares ...Middleware) Client {
+	c := &middlewareClient{
+		client:      client,
+		publish:     client.Publish,
+		middlewares: middlewares,
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i].Publish != nil {
+			c.publish = middlewares[i].Publish(c.publish)
+		}
+	}
+	return c
+}
+
+// Publish publishes the message onto the provided channel
+func (c *middlewareClient) Publish(channel string, msg Message) error {
+	return c.publish(channel, msg)
+}
+
+// Subscribe subscribes all future messages on the channel and registers a callback
+func (c *middlewareClient) Subscribe(channel string, callback MessageHandler) (Subscription, error) {
+	return c.client.Subscribe(channel, c.wrap(channel, callback))
+}
+
+// SubscribeBatch subscribes all future messages on the channel and registers a callback
+// that receives each transport message as a whole batch. Messages dropped by a middleware
+// are removed from the batch and empty batches are not handed to the callback. As for Subscribe,
+// the subscribe middlewares are applied once per subscription, so they keep their state across batches.
+func (c *middlewareClient) SubscribeBatch(channel string, callback BatchHandler) (Subscription, error) {
+	var lock sync.Mutex
+	var accepted []*Message
+	handler := c.wrap(channel, func(msg *Message) {
+		accepted = append(accepted, msg)
+	})
+	return SubscribeBatch(c.client, channel, func(batch *Batch) {
+		lock.Lock()
+		accepted = make([]*Message, 0, len(batch.Messages))
+		for _, msg := range batch.Messages {
+			handler(msg)
+		}
+		messages := accepted
+		accepted = nil
+		lock.Unlock()
+
+		if len(messages) == 0 {
+			return
+		}
+		callback(&Batch{
+			Timestamp: batch.Timestamp,
+			Messages:  messages,
+		})
+	})
+}
+
+func (c *middlewareClient) wrap(channel string, handler MessageHandler) MessageHandler {
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		if c.middlewares[i].Subscribe != nil {
+			handler = c.middlewares[i].Subscribe(channel, handler)
+		}
+	}
+	return handler
+}
+
+// LoggingMiddleware logs every published and received message
+func LoggingMiddleware() Middleware {
+	return Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(channel string, msg Message) error {
+				err := next(channel, msg)
+				if err != nil {
+					glog.Errorf("failed to publish %d bytes to %s: %s", len(msg.Payload), channel, err.Error())
+					return err
+				}
+				glog.Infof("published %d bytes to %s", len(msg.Payload), channel)
+				return nil
+			}
+		},
+		Subscribe: func(channel string, next MessageHandler) MessageHandler {
+			return func(msg *Message) {
+				glog.Infof("received %d bytes from %s", len(msg.Payload), channel)
+				next(msg)
+			}
+		},
+	}
+}
+
+// MaxPayloadSizeMiddleware rejects published messages and drops received messages
+// with a payload larger than maxBytes
+func MaxPayloadSizeMiddleware(maxBytes int) Middleware {
+	return Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(channel string, msg Message) error {
+				if len(msg.Payload) > maxBytes {
+					return fmt.Errorf("unable to publish %d bytes to %s, limit is %d: %w", len(msg.Payload), channel, maxBytes, ErrPayloadTooLarge)
+				}
+				return next(channel, msg)
+			}
+		},
+		Subscribe: func(channel string, next MessageHandler) MessageHandler {
+			return func(msg *Message) {
+				if len(msg.Payload) > maxBytes {
+					glog.Warningf("dropping %d bytes received from %s, limit is %d", len(msg.Payload), channel, maxBytes)
+					return
+				}
+				next(msg)
+			}
+		},
+	}
+}
+
+// FilterMiddleware only passes on the messages for which keep returns true.
+// Published messages that are filtered out are dropped without an error.
+func FilterMiddleware(keep func(channel string, msg *Message) bool) Middleware {
+	return Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(channel string, msg Message) error {
+				if !keep(channel, &msg) {
+					return nil
+				}
+				return next(channel, msg)
+			}
+		},
+		Subscribe: func(channel string, next MessageHandler) MessageHandler {
+			return func(msg *Message) {
+				if keep(channel, msg) {
+					next(msg)
+				}
+			}
+		},
+	}
+}
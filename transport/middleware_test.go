@@ -0,0 +1,171 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func recordingMiddleware(name string, trace *[]string) Middleware {
+	return Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(channel string, msg Message) error {
+				*trace = append(*trace, "publish:"+name)
+				return next(channel, msg)
+			}
+		},
+		Subscribe: func(channel string, next MessageHandler) MessageHandler {
+			return func(msg *Message) {
+				*trace = append(*trace, "subscribe:"+name)
+				next(msg)
+			}
+		},
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("middlewares run in order on both paths", func(t *testing.T) {
+		var trace []string
+		client := WithMiddleware(newFakeClient(), recordingMiddleware("first", &trace), recordingMiddleware("second", &trace))
+
+		_, err := client.Subscribe("channel", func(*Message) {
+			trace = append(trace, "handler")
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Publish("channel", Message{Payload: []byte("foo")}))
+
+		assert.Equal(t, []string{
+			"publish:first", "publish:second",
+			"subscribe:first", "subscribe:second", "handler",
+		}, trace)
+	})
+
+	t.Run("filter middleware short-circuits publishing and handling", func(t *testing.T) {
+		fake := newFakeClient()
+		keepFoo := FilterMiddleware(func(_ string, msg *Message) bool {
+			return string(msg.Payload) == "foo"
+		})
+		client := WithMiddleware(fake, keepFoo)
+
+		require.NoError(t, client.Publish("channel", Message{Payload: []byte("foo")}))
+		require.NoError(t, client.Publish("channel", Message{Payload: []byte("bar")}))
+		assert.Len(t, fake.published["channel"], 1)
+
+		var received []string
+		_, err := client.Subscribe("other", func(msg *Message) {
+			received = append(received, string(msg.Payload))
+		})
+		require.NoError(t, err)
+		require.NoError(t, fake.Publish("other", Message{Payload: []byte("foo")}))
+		require.NoError(t, fake.Publish("other", Message{Payload: []byte("bar")}))
+		assert.Equal(t, []string{"foo"}, received)
+	})
+
+	t.Run("size limit middleware rejects large payloads", func(t *testing.T) {
+		fake := newFakeClient()
+		client := WithMiddleware(fake, LoggingMiddleware(), MaxPayloadSizeMiddleware(3))
+
+		err := client.Publish("channel", Message{Payload: []byte("foobar")})
+		assert.ErrorIs(t, err, ErrPayloadTooLarge)
+		assert.Empty(t, fake.published["channel"])
+
+		var batches []*Batch
+		_, err = SubscribeBatch(client, "channel", func(b *Batch) {
+			batches = append(batches, b)
+		})
+		require.NoError(t, err)
+		require.NoError(t, fake.Publish("channel", Message{Payload: []byte("foobar")}))
+		require.NoError(t, client.Publish("channel", Message{Payload: []byte("foo")}))
+		require.Len(t, batches, 1)
+		assert.Equal(t, []byte("foo"), batches[0].Messages[0].Payload)
+	})
+
+	t.Run("middlewares keep their state across batches", func(t *testing.T) {
+		fake := newFakeClient()
+		count := 0
+		everySecond := Middleware{
+			Subscribe: func(channel string, next MessageHandler) MessageHandler {
+				return func(msg *Message) {
+					count++
+					if count%2 == 0 {
+						next(msg)
+					}
+				}
+			},
+		}
+		client := WithMiddleware(fake, everySecond)
+
+		var received []string
+		_, err := SubscribeBatch(client, "channel", func(b *Batch) {
+			for _, msg := range b.Messages {
+				received = append(received, string(msg.Payload))
+			}
+		})
+		require.NoError(t, err)
+		for _, payload := range []string{"a", "b", "c", "d"} {
+			require.NoError(t, fake.Publish("channel", Message{Payload: []byte(payload)}))
+		}
+		assert.Equal(t, []string{"b", "d"}, received)
+	})
+
+	t.Run("batches delivered concurrently are serialized through the middlewares", func(t *testing.T) {
+		fake := &capturingBatchClient{fakeClient: newFakeClient()}
+		client := WithMiddleware(fake, LoggingMiddleware())
+
+		var lock sync.Mutex
+		received := map[string]int{}
+		_, err := SubscribeBatch(client, "channel", func(b *Batch) {
+			lock.Lock()
+			defer lock.Unlock()
+			for _, msg := range b.Messages {
+				assert.Equal(t, b.Messages[0].Payload, msg.Payload)
+			}
+			received[string(b.Messages[0].Payload)] += len(b.Messages)
+		})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			payload := []byte(fmt.Sprintf("batch%d", i))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					fake.handler(&Batch{Messages: []*Message{{Payload: payload}, {Payload: payload}, {Payload: payload}}})
+				}
+			}()
+		}
+		wg.Wait()
+		require.Len(t, received, 8)
+		for _, count := range received {
+			assert.Equal(t, 150, count)
+		}
+	})
+
+	t.Run("clients without batch support deliver single message batches", func(t *testing.T) {
+		fake := newFakeClient()
+		var batches []*Batch
+		_, err := SubscribeBatch(struct{ Client }{fake}, "channel", func(b *Batch) {
+			batches = append(batches, b)
+		})
+		require.NoError(t, err)
+		require.NoError(t, fake.Publish("channel", Message{Payload: []byte("foo")}))
+		require.Len(t, batches, 1)
+		assert.Len(t, batches[0].Messages, 1)
+	})
+}
+
+// capturingBatchClient captures the batch handler so that batches can be delivered concurrently
+type capturingBatchClient struct {
+	*fakeClient
+	handler BatchHandler
+}
+
+func (c *capturingBatchClient) SubscribeBatch(_ string, callback BatchHandler) (Subscription, error) {
+	c.handler = callback
+	return nil, nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// orderedShardQueueSize is the number of messages that can be queued on a shard before
+// the subscription stops consuming from the transport
+const orderedShardQueueSize = 256
+
+var transportShardQueueDepthGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "transport_ordered_shard_queue_depth",
+	Help: "Number of messages waiting to be handled on a shard of the ordered subscriptions of a channel",
+}, []string{"channel", "shard"})
+
+// shardGauges counts the ordered subscriptions using each label values of the queue depth gauge, so that the
+// series shared by subscriptions on the same channel is only deleted once the last of them is stopped
+var shardGauges = struct {
+	refs map[[2]string]int
+	lock sync.Mutex
+}{refs: make(map[[2]string]int)}
+
+func acquireShardGauge(channel string, shard int) prometheus.Gauge {
+	labels := [2]string{channel, strconv.Itoa(shard)}
+	shardGauges.lock.Lock()
+	defer shardGauges.lock.Unlock()
+	shardGauges.refs[labels]++
+	return transportShardQueueDepthGauge.WithLabelValues(labels[0], labels[1])
+}
+
+func releaseShardGauge(channel string, shard int) {
+	labels := [2]string{channel, strconv.Itoa(shard)}
+	shardGauges.lock.Lock()
+	defer shardGauges.lock.Unlock()
+	shardGauges.refs[labels]--
+	if shardGauges.refs[labels] <= 0 {
+		delete(shardGauges.refs, labels)
+		transportShardQueueDepthGauge.DeleteLabelValues(labels[0], labels[1])
+	}
+}
+
+// KeyFunc defines the function signature for extracting the ordering key from a message
+type KeyFunc func(msg *Message) string
+
+// SubscribeOrdered subscribes all future messages on the channel and handles them on the given number of workers.
+// Messages are sharded across the workers by the key returned from keyFn, so messages with the same key are handled
+// in the order they were received. Unsubscribing waits for the queued messages to be handled.
+func SubscribeOrdered(client Client, channel string, workers int, keyFn KeyFunc, callback MessageHandler) (Subscription, error) {
+	orderedSub, err := newOrderedSubscription(channel, workers, keyFn, callback)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := client.Subscribe(channel, orderedSub.dispatch)
+	if err != nil {
+		orderedSub.stop()
+		return nil, err
+	}
+	orderedSub.Subscription = sub
+	return orderedSub, nil
+}
+
+// orderedSubscription dispatches messages to a fixed set of workers. Messages with the same key
+// always land on the same worker, which preserves their order.
+type orderedSubscription struct {
+	Subscription
+	channel string
+	keyFn   KeyFunc
+	handler MessageHandler
+	shards  []chan *Message
+	gauges  []prometheus.Gauge
+	wg      sync.WaitGroup
+	lock    sync.RWMutex
+	closed  bool
+}
+
+func newOrderedSubscription(channel string, workers int, keyFn KeyFunc, handler MessageHandler) (*orderedSubscription, error) {
+	if workers <= 0 {
+		return nil, fmt.Errorf("number of workers must be positive, got %d", workers)
+	}
+	if keyFn == nil {
+		return nil, fmt.Errorf("key function must not be nil")
+	}
+
+	sub := &orderedSubscription{
+		channel: channel,
+		keyFn:   keyFn,
+		handler: handler,
+		shards:  make([]chan *Message, workers),
+		gauges:  make([]prometheus.Gauge, workers),
+	}
+	for i := range sub.shards {
+		sub.shards[i] = make(chan *Message, orderedShardQueueSize)
+		sub.gauges[i] = acquireShardGauge(channel, i)
+		sub.wg.Add(1)
+		go sub.work(i)
+	}
+	return sub, nil
+}
+
+// dispatch queues the message on the shard owning its key
+func (sub *orderedSubscription) dispatch(msg *Message) {
+	sub.lock.RLock()
+	defer sub.lock.RUnlock()
+	if sub.closed {
+		return
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(sub.keyFn(msg)))
+	shard := int(h.Sum32() % uint32(len(sub.shards)))
+
+	sub.gauges[shard].Inc()
+	sub.shards[shard] <- msg
+}
+
+func (sub *orderedSubscription) work(shard int) {
+	defer sub.wg.Done()
+	for msg := range sub.shards[shard] {
+		sub.gauges[shard].Dec()
+		sub.handler(msg)
+	}
+}
+
+// Unsubscribe unsubscribes the connection and waits for the queued messages to be handled
+func (sub *orderedSubscription) Unsubscribe() error {
+	err := sub.Subscription.Unsubscribe()
+	sub.stop()
+	return err
+}
+
+// stop stops accepting messages and waits for the workers to drain their queues
+func (sub *orderedSubscription) stop() {
+	sub.lock.Lock()
+	stopping := !sub.closed
+	if stopping {
+		sub.closed = true
+		for _, shard := range sub.shards {
+			close(shard)
+		}
+	}
+	sub.lock.Unlock()
+	sub.wg.Wait()
+
+	if stopping {
+		for i := range sub.shards {
+			releaseShardGauge(sub.channel, i)
+		}
+	}
+}
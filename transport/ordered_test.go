@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSubscription struct {
+	channel string
+}
+
+func (sub *fakeSubscription) Unsubscribe() error { return nil }
+func (sub *fakeSubscription) Channel() string    { return sub.channel }
+
+func TestOrderedSubscription(t *testing.T) {
+	keyFn := func(msg *Message) string {
+		return string(msg.Payload[:1])
+	}
+
+	t.Run("constructor rejects invalid arguments", func(t *testing.T) {
+		_, err := newOrderedSubscription("ordered", 0, keyFn, func(*Message) {})
+		assert.Error(t, err)
+		_, err = newOrderedSubscription("ordered", 2, nil, func(*Message) {})
+		assert.Error(t, err)
+	})
+
+	t.Run("messages with the same key are handled in order", func(t *testing.T) {
+		var lock sync.Mutex
+		received := make(map[string][]string)
+		sub, err := newOrderedSubscription("ordered", 4, keyFn, func(msg *Message) {
+			lock.Lock()
+			defer lock.Unlock()
+			key := string(msg.Payload[:1])
+			received[key] = append(received[key], string(msg.Payload))
+		})
+		require.NoError(t, err)
+		sub.Subscription = &fakeSubscription{channel: "ordered"}
+
+		expected := make(map[string][]string)
+		for i := 0; i < 100; i++ {
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				payload := fmt.Sprintf("%s%03d", key, i)
+				expected[key] = append(expected[key], payload)
+				sub.dispatch(&Message{Payload: []byte(payload)})
+			}
+		}
+
+		require.NoError(t, sub.Unsubscribe())
+		assert.Equal(t, expected, received)
+		assert.Equal(t, "ordered", sub.Channel())
+	})
+
+	t.Run("messages dispatched after unsubscribe are dropped", func(t *testing.T) {
+		calls := 0
+		sub, err := newOrderedSubscription("ordered", 1, keyFn, func(*Message) {
+			calls++
+		})
+		require.NoError(t, err)
+		sub.Subscription = &fakeSubscription{channel: "ordered"}
+
+		require.NoError(t, sub.Unsubscribe())
+		sub.dispatch(&Message{Payload: []byte("a")})
+		assert.Equal(t, 0, calls)
+	})
+
+	t.Run("queue depth series are kept until the last subscription of the channel stops", func(t *testing.T) {
+		before := testutil.CollectAndCount(transportShardQueueDepthGauge)
+		first, err := newOrderedSubscription("shared", 2, keyFn, func(*Message) {})
+		require.NoError(t, err)
+		second, err := newOrderedSubscription("shared", 2, keyFn, func(*Message) {})
+		require.NoError(t, err)
+		assert.Equal(t, before+2, testutil.CollectAndCount(transportShardQueueDepthGauge))
+
+		first.stop()
+		first.stop()
+		assert.Equal(t, before+2, testutil.CollectAndCount(transportShardQueueDepthGauge))
+		second.stop()
+		assert.Equal(t, before, testutil.CollectAndCount(transportShardQueueDepthGauge))
+	})
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/nutanix/kps-connector-go-sdk/internal"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrRateLimited is returned when a publish is rejected by RateLimitMiddleware
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+var (
+	transportThrottledCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transport_throttled_messages",
+		Help: "Number of published messages that exceeded the rate limit of their channel",
+	}, []string{"channel", "mode"})
+	transportThrottledSecondsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transport_throttled_seconds",
+		Help: "Time spent blocking publishes that exceeded the rate limit of their channel",
+	}, []string{"channel"})
+)
+
+// RateLimitMode defines what happens to a publish that exceeds the rate limit
+type RateLimitMode int
+
+const (
+	// RateLimitBlock blocks the publish until it fits into the rate limit
+	RateLimitBlock RateLimitMode = iota
+	// RateLimitReject fails the publish with ErrRateLimited
+	RateLimitReject
+)
+
+// String returns the name of the mode
+func (m RateLimitMode) String() string {
+	switch m {
+	case RateLimitBlock:
+		return "block"
+	case RateLimitReject:
+		return "reject"
+	}
+	return fmt.Sprintf("RateLimitMode(%d)", int(m))
+}
+
+// RateLimit defines the token bucket limits for publishing onto a channel.
+// A zero rate leaves the corresponding dimension unlimited.
+type RateLimit struct {
+	// MessagesPerSecond is the sustained number of messages per second
+	MessagesPerSecond float64
+	// Burst is the number of messages that can be published at once, defaults to one second worth of messages
+	Burst int
+	// BytesPerSecond is the sustained number of payload bytes per second
+	BytesPerSecond int
+	// ByteBurst is the number of payload bytes that can be published at once, defaults to one second worth of bytes
+	ByteBurst int
+	// Mode defines whether publishes over the limit are blocked or rejected
+	Mode RateLimitMode
+}
+
+// RateLimitOpts defines the type for the functional options of RateLimitMiddleware
+type RateLimitOpts func(*rateLimiter)
+
+// RateLimitWithChannelLimit overrides the default limit for a single channel
+func RateLimitWithChannelLimit(channel string, limit RateLimit) RateLimitOpts {
+	return func(rl *rateLimiter) {
+		rl.overrides[channel] = limit
+	}
+}
+
+// RateLimitWithAlert publishes the alert once when a channel has been throttled without interruption for longer
+// than the sustained duration, and resolves it when no channel is throttled anymore
+func RateLimitWithAlert(alert events.Alert, sustained time.Duration) RateLimitOpts {
+	return func(rl *rateLimiter) {
+		rl.alert = alert
+		rl.sustained = sustained
+	}
+}
+
+type channelLimiter struct {
+	limit          RateLimit
+	messages       *internal.TokenBucket
+	bytes          *internal.TokenBucket
+	throttledSince time.Time
+	alerted        bool
+}
+
+type rateLimiter struct {
+	limit     RateLimit
+	overrides map[string]RateLimit
+	alert     events.Alert
+	sustained time.Duration
+	// alerted is the number of channels whose sustained throttling is reported by the alert
+	alerted  int
+	channels map[string]*channelLimiter
+	lock     sync.Mutex
+}
+
+// RateLimitMiddleware limits the rate of messages and payload bytes published onto each channel.
+// Every channel gets its own token buckets sized by the default limit unless it has been overridden.
+func RateLimitMiddleware(limit RateLimit, opts ...RateLimitOpts) Middleware {
+	rl := &rateLimiter{
+		limit:     limit,
+		overrides: make(map[string]RateLimit),
+		channels:  make(map[string]*channelLimiter),
+	}
+	for _, opt := range opts {
+		opt(rl)
+	}
+
+	return Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(channel string, msg Message) error {
+				if err := rl.wait(channel, len(msg.Payload)); err != nil {
+					return err
+				}
+				return next(channel, msg)
+			}
+		},
+	}
+}
+
+func (rl *rateLimiter) channelLimiter(channel string) *channelLimiter {
+	rl.lock.Lock()
+	defer rl.lock.Unlock()
+
+	cl, ok := rl.channels[channel]
+	if ok {
+		return cl
+	}
+
+	limit, ok := rl.overrides[channel]
+	if !ok {
+		limit = rl.limit
+	}
+	cl = &channelLimiter{limit: limit}
+	if limit.MessagesPerSecond > 0 {
+		burst := float64(limit.Burst)
+		if burst <= 0 {
+			burst = limit.MessagesPerSecond
+		}
+		cl.messages = internal.NewTokenBucket(limit.MessagesPerSecond, burst)
+	}
+	if limit.BytesPerSecond > 0 {
+		burst := float64(limit.ByteBurst)
+		if burst <= 0 {
+			burst = float64(limit.BytesPerSecond)
+		}
+		cl.bytes = internal.NewTokenBucket(float64(limit.BytesPerSecond), burst)
+	}
+	rl.channels[channel] = cl
+	return cl
+}
+
+// wait applies the limit of the channel to a message of the given size
+func (rl *rateLimiter) wait(channel string, size int) error {
+	cl := rl.channelLimiter(channel)
+	if cl.limit.Mode == RateLimitReject {
+		if !cl.allow(size) {
+			rl.throttled(channel, cl)
+			return fmt.Errorf("unable to publish to %s: %w", channel, ErrRateLimited)
+		}
+		rl.passed(cl)
+		return nil
+	}
+
+	delay := cl.reserve(size)
+	if delay <= 0 {
+		rl.passed(cl)
+		return nil
+	}
+	rl.throttled(channel, cl)
+	transportThrottledSecondsCounter.WithLabelValues(channel).Add(delay.Seconds())
+	time.Sleep(delay)
+	return nil
+}
+
+// allow takes a message and its bytes from the buckets if both are available and reports whether it did
+func (cl *channelLimiter) allow(size int) bool {
+	if cl.messages != nil && !cl.messages.AllowN(1) {
+		return false
+	}
+	if cl.bytes != nil && !cl.bytes.AllowN(float64(size)) {
+		if cl.messages != nil {
+			cl.messages.ReturnN(1)
+		}
+		return false
+	}
+	return true
+}
+
+func (cl *channelLimiter) reserve(size int) time.Duration {
+	var delay time.Duration
+	if cl.messages != nil {
+		delay = cl.messages.ReserveN(1)
+	}
+	if cl.bytes != nil {
+		if d := cl.bytes.ReserveN(float64(size)); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+func (rl *rateLimiter) passed(cl *channelLimiter) {
+	rl.lock.Lock()
+	cl.throttledSince = time.Time{}
+	resolve := false
+	if cl.alerted {
+		cl.alerted = false
+		rl.alerted--
+		resolve = rl.alerted == 0
+	}
+	rl.lock.Unlock()
+
+	if !resolve {
+		return
+	}
+	if err := rl.alert.Resolve(); err != nil {
+		glog.Errorf("unable to resolve throttling alert: %s", err.Error())
+	}
+}
+
+func (rl *rateLimiter) throttled(channel string, cl *channelLimiter) {
+	transportThrottledCounter.WithLabelValues(channel, cl.limit.Mode.String()).Inc()
+
+	rl.lock.Lock()
+	now := time.Now()
+	if cl.throttledSince.IsZero() {
+		cl.throttledSince = now
+	}
+	publish := rl.alert != nil && !cl.alerted && now.Sub(cl.throttledSince) >= rl.sustained
+	if publish {
+		cl.alerted = true
+		rl.alerted++
+	}
+	since := cl.throttledSince
+	rl.lock.Unlock()
+
+	if !publish {
+		return
+	}
+	err := rl.alert.Publish(events.AlertWithEventMetadata(&events.EventMetadata{
+		ErrorMessage: fmt.Sprintf("publishing to %s has been throttled since %s", channel, since.Format(time.RFC3339)),
+		Extra: map[string]interface{}{
+			"channel": channel,
+		},
+	}))
+	if err != nil {
+		glog.Errorf("unable to publish throttling alert for %s: %s", channel, err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	msg := Message{Payload: []byte("foo")}
+
+	t.Run("reject mode rejects messages over the limit per channel", func(t *testing.T) {
+		fake := newFakeClient()
+		client := WithMiddleware(fake, RateLimitMiddleware(RateLimit{
+			MessagesPerSecond: 0.001,
+			Burst:             2,
+			Mode:              RateLimitReject,
+		}, RateLimitWithChannelLimit("unlimited", RateLimit{})))
+
+		assert.NoError(t, client.Publish("channel", msg))
+		assert.NoError(t, client.Publish("channel", msg))
+		assert.ErrorIs(t, client.Publish("channel", msg), ErrRateLimited)
+		assert.NoError(t, client.Publish("other", msg))
+
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, client.Publish("unlimited", msg))
+		}
+		assert.Len(t, fake.published["channel"], 2)
+		assert.Len(t, fake.published["unlimited"], 10)
+	})
+
+	t.Run("reject mode enforces byte quotas", func(t *testing.T) {
+		fake := newFakeClient()
+		client := WithMiddleware(fake, RateLimitMiddleware(RateLimit{
+			BytesPerSecond: 1,
+			ByteBurst:      5,
+			Mode:           RateLimitReject,
+		}))
+
+		assert.NoError(t, client.Publish("channel", msg))
+		assert.ErrorIs(t, client.Publish("channel", msg), ErrRateLimited)
+	})
+
+	t.Run("publishes rejected by the byte quota keep their message token", func(t *testing.T) {
+		fake := newFakeClient()
+		client := WithMiddleware(fake, RateLimitMiddleware(RateLimit{
+			MessagesPerSecond: 0.001,
+			Burst:             2,
+			BytesPerSecond:    10,
+			Mode:              RateLimitReject,
+		}))
+
+		large := Message{Payload: make([]byte, 10)}
+		assert.NoError(t, client.Publish("channel", large))
+		assert.ErrorIs(t, client.Publish("channel", large), ErrRateLimited)
+		assert.NoError(t, client.Publish("channel", Message{}))
+		assert.Len(t, fake.published["channel"], 2)
+	})
+
+	t.Run("block mode delays messages over the limit", func(t *testing.T) {
+		fake := newFakeClient()
+		client := WithMiddleware(fake, RateLimitMiddleware(RateLimit{
+			MessagesPerSecond: 20,
+			Burst:             1,
+			Mode:              RateLimitBlock,
+		}))
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, client.Publish("channel", msg))
+		}
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(90*time.Millisecond))
+		assert.Len(t, fake.published["channel"], 3)
+	})
+
+	t.Run("sustained throttling raises an alert", func(t *testing.T) {
+		registry := events.NewRegistry()
+		alert := events.NewAlert("publishThrottled", "publishing is throttled", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, registry.RegisterAlert(alert))
+
+		client := WithMiddleware(newFakeClient(), RateLimitMiddleware(RateLimit{
+			MessagesPerSecond: 0.001,
+			Burst:             1,
+			Mode:              RateLimitReject,
+		}, RateLimitWithAlert(alert, 10*time.Millisecond)))
+
+		require.NoError(t, client.Publish("channel", msg))
+		assert.Error(t, client.Publish("channel", msg))
+		resp, err := registry.GetEvents(context.Background(), &connectorpb.GetEventsRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, resp.GetEventPayloads())
+
+		time.Sleep(20 * time.Millisecond)
+		assert.Error(t, client.Publish("channel", msg))
+		resp, err = registry.GetEvents(context.Background(), &connectorpb.GetEventsRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.GetEventPayloads(), 1)
+		assert.Equal(t, "publishThrottled", resp.GetEventPayloads()[0].GetAlert().GetId())
+	})
+
+	t.Run("the alert is published once per throttling episode and resolved afterwards", func(t *testing.T) {
+		registry := events.NewRegistry()
+		alert := events.NewAlert("publishThrottled", "publishing is throttled", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, registry.RegisterAlert(alert))
+
+		client := WithMiddleware(newFakeClient(), RateLimitMiddleware(RateLimit{
+			MessagesPerSecond: 10,
+			Burst:             1,
+			Mode:              RateLimitReject,
+		}, RateLimitWithAlert(alert, 10*time.Millisecond)))
+
+		require.NoError(t, client.Publish("channel", msg))
+		assert.Error(t, client.Publish("channel", msg))
+		time.Sleep(20 * time.Millisecond)
+		assert.Error(t, client.Publish("channel", msg))
+		assert.Error(t, client.Publish("channel", msg))
+		assert.Len(t, registry.AlertHistory("publishThrottled", ""), 1)
+
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, client.Publish("channel", msg))
+		resp, err := registry.GetEvents(context.Background(), &connectorpb.GetEventsRequest{})
+		require.NoError(t, err)
+		assert.Empty(t, resp.GetEventPayloads())
+	})
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+)
+
+const (
+	lastActivityProp = "LastActivity"
+	channelProp      = "Channel"
+)
+
+// StalenessOpts defines the type for the functional options for creating a staleness monitor
+type StalenessOpts func(*StalenessMonitor)
+
+// StalenessWithCheckInterval sets how often the streams are checked for staleness, half the window by default
+func StalenessWithCheckInterval(interval time.Duration) StalenessOpts {
+	return func(m *StalenessMonitor) {
+		m.checkInterval = interval
+	}
+}
+
+// StalenessMonitor derives the liveness of streams from the messages published and received on their channels.
+// A stream without messages for the window is reported as stalled: its status is published as UNHEALTHY and the
+// stalled alert is raised. Both recover once messages flow again.
+type StalenessMonitor struct {
+	status        events.Status
+	alert         events.Alert
+	window        time.Duration
+	checkInterval time.Duration
+	streams       map[string]*streamActivity
+	channels      map[string]time.Time
+	now           func() time.Time
+	done          chan struct{}
+	stopped       chan struct{}
+	closeOnce     sync.Once
+	// lock guards the watched streams and serializes the publications of their liveness
+	lock sync.Mutex
+	// channelsLock guards the activity of the channels. It is separate from lock because publishing the
+	// liveness can publish messages on the monitored client, e.g. through an event sink.
+	channelsLock sync.Mutex
+}
+
+// streamActivity is the liveness of a watched stream
+type streamActivity struct {
+	channel string
+	since   time.Time
+	stalled bool
+}
+
+// NewStalenessMonitor creates a monitor reporting stalled streams through the status and the alert, which
+// have to be registered with a registry. The monitor checks the streams in the background until it is closed.
+// The window and the check interval must be positive.
+func NewStalenessMonitor(status events.Status, alert events.Alert, window time.Duration, opts ...StalenessOpts) (*StalenessMonitor, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("staleness window must be positive")
+	}
+	m := &StalenessMonitor{
+		status:        status,
+		alert:         alert,
+		window:        window,
+		checkInterval: window / 2,
+		streams:       make(map[string]*streamActivity),
+		channels:      make(map[string]time.Time),
+		now:           time.Now,
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.checkInterval <= 0 {
+		return nil, fmt.Errorf("staleness check interval must be positive")
+	}
+
+	go m.run()
+	return m, nil
+}
+
+// Middleware returns the middleware recording the activity of the channels. The client used by the
+// watched streams has to be wrapped with it.
+func (m *StalenessMonitor) Middleware() Middleware {
+	return Middleware{
+		Publish: func(next PublishFunc) PublishFunc {
+			return func(channel string, msg Message) error {
+				err := next(channel, msg)
+				if err == nil {
+					m.record(channel)
+				}
+				return err
+			}
+		},
+		Subscribe: func(channel string, next MessageHandler) MessageHandler {
+			return func(msg *Message) {
+				m.record(channel)
+				next(msg)
+			}
+		},
+	}
+}
+
+// Watch starts monitoring the stream of the handle and publishes its status as HEALTHY. The stream
+// gets a full window before it can be reported as stalled.
+func (m *StalenessMonitor) Watch(stream *StreamHandle) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.streams[stream.StreamID()] = &streamActivity{
+		channel: stream.Channel(),
+		since:   m.now(),
+	}
+	return m.status.Publish(events.StatusWithStreamID(stream.StreamID()), events.StatusWithState(connectorpb.State_STATE_HEALTHY))
+}
+
+// Unwatch stops monitoring the stream, clearing its status and resolving its alert
+func (m *StalenessMonitor) Unwatch(streamID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.streams, streamID)
+	if err := m.alert.Resolve(events.AlertWithStreamID(streamID)); err != nil {
+		return err
+	}
+	return m.status.Clear(events.StatusWithStreamID(streamID))
+}
+
+// Close stops checking the streams in the background
+func (m *StalenessMonitor) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+	<-m.stopped
+}
+
+func (m *StalenessMonitor) record(channel string) {
+	now := m.now()
+	m.channelsLock.Lock()
+	m.channels[channel] = now
+	m.channelsLock.Unlock()
+}
+
+func (m *StalenessMonitor) run() {
+	defer close(m.stopped)
+	ticker := time.NewTicker(m.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.check()
+		}
+	}
+}
+
+// check reports the streams that became stalled and recovers the ones that became active again
+func (m *StalenessMonitor) check() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	now := m.now()
+	for streamID, stream := range m.streams {
+		lastActivity := stream.since
+		if t, ok := m.channelActivity(stream.channel); ok && t.After(lastActivity) {
+			lastActivity = t
+		}
+		stalled := now.Sub(lastActivity) >= m.window
+		if stalled == stream.stalled {
+			continue
+		}
+
+		var err error
+		if stalled {
+			err = m.stall(streamID, stream, lastActivity)
+		} else {
+			err = m.recover(streamID)
+		}
+		if err != nil {
+			glog.Errorf("unable to update liveness of stream %s: %s", streamID, err.Error())
+			continue
+		}
+		stream.stalled = stalled
+	}
+}
+
+func (m *StalenessMonitor) channelActivity(channel string) (time.Time, bool) {
+	m.channelsLock.Lock()
+	defer m.channelsLock.Unlock()
+	t, ok := m.channels[channel]
+	return t, ok
+}
+
+func (m *StalenessMonitor) stall(streamID string, stream *streamActivity, lastActivity time.Time) error {
+	metadata := &events.EventMetadata{
+		StreamID: streamID,
+		Extra: map[string]interface{}{
+			channelProp:      stream.channel,
+			lastActivityProp: lastActivity,
+		},
+	}
+	err := m.status.Publish(
+		events.StatusWithStreamID(streamID),
+		events.StatusWithState(connectorpb.State_STATE_UNHEALTHY),
+		events.StatusWithEventMetadata(metadata),
+	)
+	if err != nil {
+		return err
+	}
+	return m.alert.Publish(events.AlertWithStreamID(streamID), events.AlertWithEventMetadata(metadata))
+}
+
+func (m *StalenessMonitor) recover(streamID string) error {
+	err := m.status.Publish(events.StatusWithStreamID(streamID), events.StatusWithState(connectorpb.State_STATE_HEALTHY))
+	if err != nil {
+		return err
+	}
+	return m.alert.Resolve(events.AlertWithStreamID(streamID))
+}
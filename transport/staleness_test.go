@@ -0,0 +1,142 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClock struct {
+	t    time.Time
+	lock sync.Mutex
+}
+
+func (c *testClock) now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestStalenessMonitor(t *testing.T) {
+	stream := &connectorpb.Stream{
+		Id:               "ingress-stream",
+		Direction:        connectorpb.StreamDirection_STREAM_DIRECTION_INGRESS,
+		TransportChannel: "ingress-channel",
+	}
+	streamEvents := func(t *testing.T, reg *events.Registry) (connectorpb.State, bool) {
+		resp, err := reg.GetEvents(context.Background(), &connectorpb.GetEventsRequest{})
+		require.NoError(t, err)
+		var state connectorpb.State
+		var alerted bool
+		for _, payload := range resp.GetEventPayloads() {
+			if payload.GetStatus().GetStreamId() == stream.Id {
+				state = payload.GetStatus().GetState()
+			}
+			if payload.GetAlert().GetStreamId() == stream.Id {
+				alerted = true
+			}
+		}
+		return state, alerted
+	}
+
+	t.Run("streams without messages are stalled until traffic resumes", func(t *testing.T) {
+		reg := events.NewRegistry()
+		status := events.NewStatus("streamActivity", "stream activity", connectorpb.State_STATE_HEALTHY)
+		alert := events.NewAlert("stalledStream", "stream is stalled", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterStatus(status))
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		clock := &testClock{t: time.Unix(0, 0)}
+		monitor, err := NewStalenessMonitor(status, alert, time.Minute, StalenessWithCheckInterval(time.Hour))
+		require.NoError(t, err)
+		defer monitor.Close()
+		monitor.now = clock.now
+		client := WithMiddleware(newFakeClient(), monitor.Middleware())
+		h, err := NewStreamHandle(client, stream)
+		require.NoError(t, err)
+
+		require.NoError(t, monitor.Watch(h))
+		state, alerted := streamEvents(t, reg)
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, state)
+		assert.False(t, alerted)
+
+		clock.advance(30 * time.Second)
+		require.NoError(t, h.Publish(Message{Payload: []byte("foo")}))
+		clock.advance(45 * time.Second)
+		monitor.check()
+		state, alerted = streamEvents(t, reg)
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, state)
+		assert.False(t, alerted)
+
+		clock.advance(15 * time.Second)
+		monitor.check()
+		state, alerted = streamEvents(t, reg)
+		assert.Equal(t, connectorpb.State_STATE_UNHEALTHY, state)
+		assert.True(t, alerted)
+
+		require.NoError(t, h.Publish(Message{Payload: []byte("foo")}))
+		monitor.check()
+		state, alerted = streamEvents(t, reg)
+		assert.Equal(t, connectorpb.State_STATE_HEALTHY, state)
+		assert.False(t, alerted)
+
+		require.NoError(t, monitor.Unwatch(stream.Id))
+		state, _ = streamEvents(t, reg)
+		assert.Equal(t, connectorpb.State_STATE_UNSPECIFIED, state)
+	})
+
+	t.Run("liveness can be published through an event sink on the monitored client", func(t *testing.T) {
+		reg := events.NewRegistry()
+		status := events.NewStatus("streamActivity", "stream activity", connectorpb.State_STATE_HEALTHY)
+		alert := events.NewAlert("stalledStream", "stream is stalled", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, reg.RegisterStatus(status))
+		require.NoError(t, reg.RegisterAlert(alert))
+
+		clock := &testClock{t: time.Unix(0, 0)}
+		monitor, err := NewStalenessMonitor(status, alert, time.Minute, StalenessWithCheckInterval(time.Hour))
+		require.NoError(t, err)
+		defer monitor.Close()
+		monitor.now = clock.now
+		client := WithMiddleware(newFakeClient(), monitor.Middleware())
+		reg.AddSink(NewEventSink(client, "connector-events"))
+		h, err := NewStreamHandle(client, stream)
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			assert.NoError(t, monitor.Watch(h))
+			clock.advance(2 * time.Minute)
+			monitor.check()
+			assert.NoError(t, monitor.Unwatch(stream.Id))
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("publishing the liveness of the stream did not return")
+		}
+	})
+
+	t.Run("the window and the check interval must be positive", func(t *testing.T) {
+		status := events.NewStatus("streamActivity", "stream activity", connectorpb.State_STATE_HEALTHY)
+		alert := events.NewAlert("stalledStream", "stream is stalled", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+
+		_, err := NewStalenessMonitor(status, alert, 0)
+		assert.Error(t, err)
+		_, err = NewStalenessMonitor(status, alert, time.Minute, StalenessWithCheckInterval(0))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"errors"
+	"fmt"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrWrongDirection is returned when a stream handle is used against the direction of its stream
+var ErrWrongDirection = errors.New("operation not allowed for stream direction")
+
+var (
+	transportStreamPublishedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transport_stream_published_messages",
+		Help: "Number of messages published by a stream",
+	}, []string{"stream_id"})
+	transportStreamReceivedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transport_stream_received_messages",
+		Help: "Number of messages received by a stream",
+	}, []string{"stream_id"})
+	transportStreamErrorCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "transport_stream_errors",
+		Help: "Number of transport errors encountered by a stream",
+	}, []string{"stream_id"})
+)
+
+// StreamHandle binds a transport client to a single stream. An INGRESS stream can only publish
+// to its transport channel and an EGRESS stream can only subscribe to it. Metrics and events
+// published through the handle are tagged with the stream ID.
+type StreamHandle struct {
+	client Client
+	stream *connectorpb.Stream
+}
+
+// NewStreamHandle creates a handle that uses the client for transporting the data of the stream
+func NewStreamHandle(client Client, stream *connectorpb.Stream) (*StreamHandle, error) {
+	if client == nil {
+		return nil, fmt.Errorf("transport client must not be nil")
+	}
+	if stream == nil {
+		return nil, fmt.Errorf("stream must not be nil")
+	}
+	if stream.GetTransportChannel() == "" {
+		return nil, fmt.Errorf("stream %s has no transport channel", stream.GetId())
+	}
+	switch stream.GetDirection() {
+	case connectorpb.StreamDirection_STREAM_DIRECTION_INGRESS, connectorpb.StreamDirection_STREAM_DIRECTION_EGRESS:
+	default:
+		return nil, fmt.Errorf("stream %s has unsupported direction %s", stream.GetId(), stream.GetDirection())
+	}
+
+	return &StreamHandle{
+		client: client,
+		stream: stream,
+	}, nil
+}
+
+// StreamID returns the ID of the stream the handle is bound to
+func (h *StreamHandle) StreamID() string {
+	return h.stream.GetId()
+}
+
+// Channel returns the transport channel of the stream the handle is bound to
+func (h *StreamHandle) Channel() string {
+	return h.stream.GetTransportChannel()
+}
+
+// Direction returns the direction of the stream the handle is bound to
+func (h *StreamHandle) Direction() connectorpb.StreamDirection {
+	return h.stream.GetDirection()
+}
+
+// Publish publishes the message onto the channel of an INGRESS stream
+func (h *StreamHandle) Publish(msg Message) error {
+	if err := h.allow(connectorpb.StreamDirection_STREAM_DIRECTION_INGRESS, "publish"); err != nil {
+		return err
+	}
+
+	err := h.client.Publish(h.Channel(), msg)
+	if err != nil {
+		transportStreamErrorCounter.WithLabelValues(h.StreamID()).Inc()
+		return err
+	}
+	transportStreamPublishedCounter.WithLabelValues(h.StreamID()).Inc()
+	return nil
+}
+
+// Subscribe subscribes all future messages on the channel of an EGRESS stream
+func (h *StreamHandle) Subscribe(callback MessageHandler) (Subscription, error) {
+	if err := h.allow(connectorpb.StreamDirection_STREAM_DIRECTION_EGRESS, "subscribe"); err != nil {
+		return nil, err
+	}
+	return h.track(h.client.Subscribe(h.Channel(), h.countMessage(callback)))
+}
+
+// SubscribeBatch subscribes all future messages on the channel of an EGRESS stream as batches
+func (h *StreamHandle) SubscribeBatch(callback BatchHandler) (Subscription, error) {
+	if err := h.allow(connectorpb.StreamDirection_STREAM_DIRECTION_EGRESS, "subscribe"); err != nil {
+		return nil, err
+	}
+	counter := transportStreamReceivedCounter.WithLabelValues(h.StreamID())
+	return h.track(SubscribeBatch(h.client, h.Channel(), func(batch *Batch) {
+		counter.Add(float64(len(batch.Messages)))
+		callback(batch)
+	}))
+}
+
+// SubscribeOrdered subscribes all future messages on the channel of an EGRESS stream and handles them
+// on the given number of workers, preserving the order of messages with the same key
+func (h *StreamHandle) SubscribeOrdered(workers int, keyFn KeyFunc, callback MessageHandler) (Subscription, error) {
+	if err := h.allow(connectorpb.StreamDirection_STREAM_DIRECTION_EGRESS, "subscribe"); err != nil {
+		return nil, err
+	}
+	return h.track(SubscribeOrdered(h.client, h.Channel(), workers, keyFn, h.countMessage(callback)))
+}
+
+// PublishAlert publishes the alert scoped to the stream of the handle
+func (h *StreamHandle) PublishAlert(alert events.Alert, opts ...events.AlertOpts) error {
+	opts = append([]events.AlertOpts{events.AlertWithStreamID(h.StreamID())}, opts...)
+	return alert.Publish(opts...)
+}
+
+// PublishStatus publishes the status scoped to the stream of the handle
+func (h *StreamHandle) PublishStatus(status events.Status, opts ...events.StatusOpts) error {
+	opts = append([]events.StatusOpts{events.StatusWithStreamID(h.StreamID())}, opts...)
+	return status.Publish(opts...)
+}
+
+func (h *StreamHandle) allow(direction connectorpb.StreamDirection, operation string) error {
+	if h.Direction() != direction {
+		return fmt.Errorf("unable to %s on %s stream %s: %w", operation, h.Direction(), h.StreamID(), ErrWrongDirection)
+	}
+	return nil
+}
+
+func (h *StreamHandle) countMessage(callback MessageHandler) MessageHandler {
+	counter := transportStreamReceivedCounter.WithLabelValues(h.StreamID())
+	return func(msg *Message) {
+		counter.Inc()
+		callback(msg)
+	}
+}
+
+func (h *StreamHandle) track(sub Subscription, err error) (Subscription, error) {
+	if err != nil {
+		transportStreamErrorCounter.WithLabelValues(h.StreamID()).Inc()
+		return nil, err
+	}
+	return sub, nil
+}
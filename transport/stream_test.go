@@ -0,0 +1,123 @@
+// Copyright (c) 2021 Nutanix, Inc.
+package transport
+
+import (
+	"context"
+	"testing"
+
+	connectorpb "github.com/nutanix/kps-connector-go-sdk/connector/v1"
+	"github.com/nutanix/kps-connector-go-sdk/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClient is an in-memory transport client that delivers published messages to its subscribers
+type fakeClient struct {
+	published   map[string][]Message
+	subscribers map[string][]MessageHandler
+}
+
+var _ Client = (*fakeClient)(nil)
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		published:   make(map[string][]Message),
+		subscribers: make(map[string][]MessageHandler),
+	}
+}
+
+func (c *fakeClient) Publish(channel string, msg Message) error {
+	c.published[channel] = append(c.published[channel], msg)
+	for _, cb := range c.subscribers[channel] {
+		cb(&Message{Payload: msg.Payload})
+	}
+	return nil
+}
+
+func (c *fakeClient) Subscribe(channel string, callback MessageHandler) (Subscription, error) {
+	c.subscribers[channel] = append(c.subscribers[channel], callback)
+	return &fakeSubscription{channel: channel}, nil
+}
+
+func (c *fakeClient) SubscribeBatch(channel string, callback BatchHandler) (Subscription, error) {
+	return c.Subscribe(channel, func(msg *Message) {
+		callback(&Batch{Messages: []*Message{msg}})
+	})
+}
+
+func TestStreamHandle(t *testing.T) {
+	ingress := &connectorpb.Stream{
+		Id:               "ingress-stream",
+		Direction:        connectorpb.StreamDirection_STREAM_DIRECTION_INGRESS,
+		TransportChannel: "ingress-channel",
+	}
+	egress := &connectorpb.Stream{
+		Id:               "egress-stream",
+		Direction:        connectorpb.StreamDirection_STREAM_DIRECTION_EGRESS,
+		TransportChannel: "egress-channel",
+	}
+
+	t.Run("constructor rejects invalid streams", func(t *testing.T) {
+		client := newFakeClient()
+		_, err := NewStreamHandle(client, nil)
+		assert.Error(t, err)
+		_, err = NewStreamHandle(client, &connectorpb.Stream{Id: "s", Direction: connectorpb.StreamDirection_STREAM_DIRECTION_INGRESS})
+		assert.Error(t, err)
+		_, err = NewStreamHandle(client, &connectorpb.Stream{Id: "s", TransportChannel: "c"})
+		assert.Error(t, err)
+		_, err = NewStreamHandle(nil, ingress)
+		assert.Error(t, err)
+	})
+
+	t.Run("ingress stream can only publish", func(t *testing.T) {
+		client := newFakeClient()
+		h, err := NewStreamHandle(client, ingress)
+		require.NoError(t, err)
+		assert.Equal(t, "ingress-stream", h.StreamID())
+		assert.Equal(t, "ingress-channel", h.Channel())
+
+		err = h.Publish(Message{Payload: []byte("foo")})
+		require.NoError(t, err)
+		assert.Len(t, client.published["ingress-channel"], 1)
+
+		_, err = h.Subscribe(func(*Message) {})
+		assert.ErrorIs(t, err, ErrWrongDirection)
+		_, err = h.SubscribeBatch(func(*Batch) {})
+		assert.ErrorIs(t, err, ErrWrongDirection)
+		_, err = h.SubscribeOrdered(2, func(*Message) string { return "" }, func(*Message) {})
+		assert.ErrorIs(t, err, ErrWrongDirection)
+	})
+
+	t.Run("egress stream can only subscribe", func(t *testing.T) {
+		client := newFakeClient()
+		h, err := NewStreamHandle(client, egress)
+		require.NoError(t, err)
+
+		received := 0
+		sub, err := h.Subscribe(func(*Message) { received++ })
+		require.NoError(t, err)
+		assert.Equal(t, "egress-channel", sub.Channel())
+
+		require.NoError(t, client.Publish("egress-channel", Message{Payload: []byte("foo")}))
+		assert.Equal(t, 1, received)
+
+		err = h.Publish(Message{Payload: []byte("foo")})
+		assert.ErrorIs(t, err, ErrWrongDirection)
+		assert.Empty(t, client.published["egress-channel"][1:])
+	})
+
+	t.Run("events published through the handle are tagged with the stream ID", func(t *testing.T) {
+		h, err := NewStreamHandle(newFakeClient(), ingress)
+		require.NoError(t, err)
+
+		registry := events.NewRegistry()
+		alert := events.NewAlert("streamAlert", "alert", connectorpb.Severity_SEVERITY_WARNING, connectorpb.State_STATE_UNHEALTHY)
+		require.NoError(t, registry.RegisterAlert(alert))
+		require.NoError(t, h.PublishAlert(alert))
+
+		resp, err := registry.GetEvents(context.Background(), &connectorpb.GetEventsRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.GetEventPayloads(), 1)
+		assert.Equal(t, "ingress-stream", resp.GetEventPayloads()[0].GetAlert().GetStreamId())
+	})
+}
@@ -43,7 +43,9 @@ var (
 )
 
 func init() {
-	statsRegistry.MustRegister(transportConnectErrorCounter, transportPublishErrorCounter)
+	statsRegistry.MustRegister(transportConnectErrorCounter, transportPublishErrorCounter, transportShardQueueDepthGauge,
+		transportStreamPublishedCounter, transportStreamReceivedCounter, transportStreamErrorCounter,
+		transportThrottledCounter, transportThrottledSecondsCounter)
 
 	// Start the pushgateway pusher go routine which periodically pushes
 	// prometheus metrics of transport to pushgateway
@@ -73,6 +75,17 @@ type Message struct {
 // MessageHandler defines the function signature for the callback function in a Subscribe call
 type MessageHandler func(msg *Message)
 
+// Batch defines the group of messages conveyed by the transport in a single transport message
+type Batch struct {
+	// Timestamp is the time at which the batch was published
+	Timestamp time.Time
+	// Messages are the messages of the batch in the order they were published
+	Messages []*Message
+}
+
+// BatchHandler defines the function signature for the callback function in a SubscribeBatch call
+type BatchHandler func(batch *Batch)
+
 // Client describes the publish / subscribe interface of the transport client
 type Client interface {
 	// Publish publishes the message onto the provided channel
@@ -81,6 +94,25 @@ type Client interface {
 	Subscribe(channel string, callback MessageHandler) (Subscription, error)
 }
 
+// BatchSubscriber is implemented by clients that can deliver each transport message as a whole batch
+type BatchSubscriber interface {
+	// SubscribeBatch subscribes all future messages on the channel and registers a callback
+	// that receives each transport message as a whole batch
+	SubscribeBatch(channel string, callback BatchHandler) (Subscription, error)
+}
+
+// SubscribeBatch subscribes all future messages on the channel with a callback that receives each transport
+// message as a whole batch. Clients that do not implement BatchSubscriber deliver batches of a single message
+// without a timestamp.
+func SubscribeBatch(client Client, channel string, callback BatchHandler) (Subscription, error) {
+	if batchClient, ok := client.(BatchSubscriber); ok {
+		return batchClient.SubscribeBatch(channel, callback)
+	}
+	return client.Subscribe(channel, func(msg *Message) {
+		callback(&Batch{Messages: []*Message{msg}})
+	})
+}
+
 // Subscription describes the interface of the subscription object
 type Subscription interface {
 	// Unsubscribe unsubscribes the connection
@@ -183,18 +215,41 @@ func (client *natsClient) Subscribe(subject string, cb MessageHandler) (Subscrip
 	return &natsSubscription{Subscription: natsSub}, nil
 }
 
+// SubscribeBatch subscribes all future messages on the channel and registers a callback
+// that receives each transport message as a whole batch
+func (client *natsClient) SubscribeBatch(subject string, cb BatchHandler) (Subscription, error) {
+	natsSub, err := client.conn.Subscribe(subject, client.natsBatchHandler(cb))
+	if err != nil {
+		return nil, err
+	}
+	return &natsSubscription{Subscription: natsSub}, nil
+}
+
 func (client *natsClient) natsMsgHandler(handler MessageHandler) nats.MsgHandler {
+	return client.natsBatchHandler(func(batch *Batch) {
+		for _, msg := range batch.Messages {
+			handler(msg)
+		}
+	})
+}
+
+func (client *natsClient) natsBatchHandler(handler BatchHandler) nats.MsgHandler {
 	return func(msg *nats.Msg) {
 		var tMsg connectorpb.TransportMessage
 		err := proto.Unmarshal(msg.Data, &tMsg)
 		if err != nil {
 			log.Printf("unable to unmarshal data from %s", msg.Subject)
+			return
+		}
+		batch := &Batch{
+			Timestamp: time.Unix(0, tMsg.GetTimestamp()),
+			Messages:  make([]*Message, 0, len(tMsg.GetPayload())),
 		}
 		for _, payload := range tMsg.GetPayload() {
-			hMsg := &Message{
+			batch.Messages = append(batch.Messages, &Message{
 				Payload: payload,
-			}
-			handler(hMsg)
+			})
 		}
+		handler(batch)
 	}
 }
@@ -116,6 +116,44 @@ func TestNewTransportClient(t *testing.T) {
 		assert.True(t, validateMap["foo"])
 		assert.True(t, validateMap["bar"])
 
+		err = sub.Unsubscribe()
+		assert.NoError(t, err)
+	})
+	t.Run("batch callback receives all messages in one nats payload", func(t *testing.T) {
+		channel := "testbatchchannel"
+		client, err := NewTransportClient()
+		require.NoError(t, err)
+		require.NotNil(t, client)
+
+		batches := make(chan *Batch, 1)
+		sub, err := SubscribeBatch(client, channel, func(b *Batch) {
+			batches <- b
+		})
+		require.NoError(t, err)
+		assert.Equal(t, channel, sub.Channel())
+
+		nc, ok := client.(*natsClient)
+		require.True(t, ok)
+		ts := time.Now()
+		tMsg := &connectorpb.TransportMessage{
+			Timestamp: ts.UnixNano(),
+			Payload:   [][]byte{[]byte("foo"), []byte("bar")},
+		}
+		data, err := proto.Marshal(tMsg)
+		require.NoError(t, err)
+		err = nc.conn.Publish(channel, data)
+		require.NoError(t, err)
+
+		select {
+		case b := <-batches:
+			assert.Equal(t, ts.UnixNano(), b.Timestamp.UnixNano())
+			require.Len(t, b.Messages, 2)
+			assert.Equal(t, []byte("foo"), b.Messages[0].Payload)
+			assert.Equal(t, []byte("bar"), b.Messages[1].Payload)
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out waiting for batch")
+		}
+
 		err = sub.Unsubscribe()
 		assert.NoError(t, err)
 	})